@@ -1,113 +1,199 @@
 package main
 
 import (
+	"fmt"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"github.com/fatih/color"
 )
 
-func acceptCommand(flags acceptFlags, testname string) {
-	if flags.all {
-		acceptAll()
+// acceptCommand accepts the results of every test matching any of targets,
+// which may be globs, in the sets and phases selected by flags
+func acceptCommand(flags acceptFlags, targets []string) {
+	if flags.history {
+		printHistory()
 		return
 	}
 
-	var path string
-	if flags.asm {
-		filename := testname + asmExt
-		path = buildPath(asmDir, filename)
-	} else {
-		filename := testname + pikaExt
-		path = buildPath(pikaDir, filename)
+	if flags.all {
+		targets = []string{"*"}
 	}
 
-	if !exists(path) {
-		color.Magenta(path + " does not exist")
-		os.Exit(1)
-		return // not necessary, just to be explicit
+	for _, target := range targets {
+		if _, err := filepath.Match(target, ""); err != nil {
+			color.Magenta(target + " is not a valid glob")
+			os.Exit(1)
+		}
+		if isGlob(target) {
+			continue
+		}
+		path := testPath(target, flags.asm || flags.testSet == optimizerStandalone)
+		if !exists(path) {
+			color.Magenta(path + " does not exist")
+			os.Exit(1)
+			return // not necessary, just to be explicit
+		}
 	}
 
-	if flags.asm {
-		acceptOptimizedStandalone(testname)
-	} else {
-		acceptCodeGen(testname)
-		acceptOptimized(testname)
-		acceptCompile(testname)
+	tx := beginTransaction("accept " + strings.Join(os.Args[2:], " "))
+	record := loadRunRecord()
+	refused := 0
+	for _, output := range selectOutputs(flags, targets) {
+		if reason := acceptRefusal(output, record); reason != "" && !flags.force {
+			color.Red("refused " + output.resultPath() + ": " + reason)
+			refused++
+			continue
+		}
+		acceptOutput(tx, output)
+	}
+	if refused > 0 {
+		color.Yellow(fmt.Sprint("refused ", refused, " results which look broken, ") +
+			"accept them anyway with -force")
 	}
-}
-
-func acceptAll() {
-	os.RemoveAll(backupDir)
-	// os.Rename(expectDir, backupDir)
-	// os.Rename(resultDir, expectDir)
-	// initResultDirs()
-	backup()
-	acceptAllPika()
-	acceptAllStandalone()
-}
-
-func acceptAllPika() {
-	files := getAllFiles(pikaDir)
-	files = filterOutFiles(files, ".gitignore")
 
-	for _, file := range files {
-		testname := replaceExtension(file.Name(), "")
-		acceptCodeGen(testname)
-		acceptOptimized(testname)
-		acceptCompile(testname)
+	if flags.dryRun {
+		previewAccept(tx)
+		return
 	}
+	commitAccept(tx, flags.reason)
 }
 
-func acceptAllStandalone() {
-	files := getAllFiles(asmDir)
-	files = filterOutFiles(files, ".gitignore")
+// selectOutputs lists every phase of every test that flags and targets pick
+func selectOutputs(flags acceptFlags, targets []string) []testOutput {
+	var sets []string
+	switch {
+	case flags.asm:
+		sets = []string{optimizerStandalone}
+	case flags.testSet != "":
+		sets = []string{flags.testSet}
+	case flags.all:
+		sets = testSets
+	default:
+		sets = []string{codegenerator, optimizer, compiler}
+	}
+	selectedPhases := phases
+	if flags.phase != "" {
+		selectedPhases = []string{flags.phase}
+	}
 
-	for _, file := range files {
-		testname := replaceExtension(file.Name(), "")
-		acceptOptimizedStandalone(testname)
+	outputs := []testOutput{}
+	for _, testSet := range sets {
+		for _, testname := range testNames(testSet) {
+			if !matchesAny(testname, targets) {
+				continue
+			}
+			for _, phase := range selectedPhases {
+				outputs = append(outputs, testOutput{testname, testSet, phase})
+			}
+		}
 	}
+	return outputs
 }
 
-func backup() {
-	exec.Command("cp", "-rf", expectDir, backupDir).Run()
+func matchesAny(testname string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, testname); matched {
+			return true
+		}
+	}
+	return false
 }
 
-func acceptTest(testname, oldPath, newPath, ext string) {
-	old := buildPath(oldPath, testname+ext)
-	new := buildPath(newPath, testname+ext)
-	moveIfExists(old, new)
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[\\")
 }
 
-func acceptCompile(testname string) {
-	acceptTest(testname, result.build.compiler, expect.build.compiler, txtExt)
-	acceptTest(testname, result.run.compiler, expect.run.compiler, txtExt)
-	acceptTest(testname, result.asm.compiler, expect.asm.compiler, asmExt)
-
-	acceptTest(testname, result.buildo.compiler, expect.buildo.compiler, txtExt)
-	acceptTest(testname, result.asmo.compiler, expect.asmo.compiler, asmoExt)
+// previewAccept shows what committing tx would do to each expectation
+func previewAccept(tx *transaction) {
+	unchanged := 0
+	for _, change := range tx.Changes {
+		if compareResult(change.Source, change.Path) {
+			unchanged++
+			continue
+		}
+		expectPath := change.Path
+		if !exists(expectPath) {
+			color.Cyan("new " + change.Path)
+			expectPath = os.DevNull
+		} else {
+			color.Cyan("changed " + change.Path)
+		}
+		printDiff(makeDiff(expectPath, change.Source), unifiedDiff)
+	}
+	fmt.Println("would change", len(tx.Changes)-unchanged, "expectations,",
+		unchanged, "are already up to date")
 }
 
-func acceptCodeGen(testname string) {
-	acceptTest(testname, result.build.codegenerator, expect.build.codegenerator, txtExt)
-	acceptTest(testname, result.run.codegenerator, expect.run.codegenerator, txtExt)
-	acceptTest(testname, result.asm.codegenerator, expect.asm.codegenerator, asmExt)
+// commitAccept makes every change an accept queued up, or none of them,
+// recording the provenance of each new expectation along with them
+func commitAccept(tx *transaction, reason string) {
+	accepted := len(tx.Changes)
+	if accepted == 0 {
+		color.Yellow("there are no results to accept")
+		return
+	}
+	recordProvenance(tx, reason)
+	err := tx.commit()
+	if err != nil {
+		color.Magenta("accept failed, nothing was changed: " + err.Error())
+		os.Exit(1)
+	}
+	applyProvenance(tx)
+	fmt.Println("accepted", accepted, "results, undo with: gtr undo", tx.ID)
 }
 
-func acceptOptimized(testname string) {
-	acceptTest(testname, result.build.optimizer, expect.build.optimizer, txtExt)
-	acceptTest(testname, result.run.optimizer, expect.run.optimizer, txtExt)
-	acceptTest(testname, result.asm.optimizer, expect.asm.optimizer, asmoExt)
+// acceptRefusal explains why the result of output looks too broken to be
+// accepted, or returns the empty string if it looks fine
+func acceptRefusal(output testOutput, record runRecord) string {
+	resultPath := output.resultPath()
+	if resultPath == "" || !exists(resultPath) {
+		return ""
+	}
+	contents := readFile(resultPath)
+	if hasStackTrace(contents) {
+		return "it contains a java stack trace"
+	}
+	if timedOut(contents) {
+		return "the tool which wrote it timed out"
+	}
+	if limit := exceededLimit(contents); limit != "" {
+		return "the tool which wrote it exceeded its limit on " + limit
+	}
+	if output.phase == run && contents == "" && readFile(output.expectPath()) != "" {
+		return "the run printed nothing, but the expectation isn't empty"
+	}
 
-	acceptTest(testname, result.buildo.optimizer, expect.buildo.optimizer, txtExt)
-	acceptTest(testname, result.asmo.optimizer, expect.asmo.optimizer, asmoExt)
+	// asm is written by the tool whose output is the build result
+	writer := output
+	switch output.phase {
+	case asm:
+		writer.phase = build
+	case asmo:
+		writer.phase = buildo
+	}
+	if exitCode := record.exitCode(writer.resultPath()); exitCode != 0 {
+		return fmt.Sprint("the tool which wrote it exited with code ", exitCode)
+	}
+	return ""
 }
 
-func acceptOptimizedStandalone(testname string) {
-	acceptTest(testname, result.build.optimizerStandalone, expect.build.optimizerStandalone, txtExt)
-	acceptTest(testname, result.run.optimizerStandalone, expect.run.optimizerStandalone, txtExt)
-	acceptTest(testname, result.asm.optimizerStandalone, expect.asm.optimizerStandalone, asmoExt)
+func acceptTest(tx *transaction, testname, oldPath, newPath, ext string) {
+	old := buildPath(oldPath, testname+ext)
+	new := buildPath(newPath, testname+ext)
+	if exists(old) {
+		tx.replace(old, new)
+	}
+}
 
-	acceptTest(testname, result.buildo.optimizerStandalone, expect.buildo.optimizerStandalone, txtExt)
-	acceptTest(testname, result.asmo.optimizerStandalone, expect.asmo.optimizerStandalone, asmoExt)
+// acceptOutput accepts the result of a single phase of a test
+func acceptOutput(tx *transaction, output testOutput) {
+	if output.resultPath() == "" {
+		return
+	}
+	acceptTest(tx, output.testname,
+		result.dir(output.phase, output.testSet),
+		expect.dir(output.phase, output.testSet),
+		outputExt(output.phase, output.testSet))
 }
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ASMEmu opcodes gtr needs to know about
+const (
+	labelOp  = "Label"
+	dLabelOp = "DLabel"
+)
+
+// asmInstruction is a single line of an ASMEmu program
+type asmInstruction struct {
+	opcode  string
+	operand string
+}
+
+// parseAsmLine splits a line of ASMEmu source into its opcode and operand,
+// blank lines have an empty opcode
+func parseAsmLine(line string) asmInstruction {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return asmInstruction{}
+	}
+	operand := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), fields[0]))
+	return asmInstruction{opcode: fields[0], operand: operand}
+}
+
+func parseAsm(source string) []asmInstruction {
+	instructions := []asmInstruction{}
+	for _, line := range splitLines(source) {
+		instruction := parseAsmLine(line)
+		if instruction.opcode != "" {
+			instructions = append(instructions, instruction)
+		}
+	}
+	return instructions
+}
+
+func (instruction asmInstruction) definesLabel() bool {
+	return instruction.opcode == labelOp || instruction.opcode == dLabelOp
+}
+
+// definedLabels is the set of every code and data label in a program
+func definedLabels(instructions []asmInstruction) map[string]bool {
+	labels := map[string]bool{}
+	for _, instruction := range instructions {
+		if instruction.definesLabel() {
+			labels[instruction.operand] = true
+		}
+	}
+	return labels
+}
+
+// labelsResolve reports whether every operand in instructions that names one
+// of labels is still defined somewhere in instructions
+func labelsResolve(instructions []asmInstruction, labels map[string]bool) bool {
+	defined := definedLabels(instructions)
+	for _, instruction := range instructions {
+		if instruction.definesLabel() {
+			continue
+		}
+		if labels[instruction.operand] && !defined[instruction.operand] {
+			return false
+		}
+	}
+	return true
+}
+
+// asmCycles is a rough cost in cycles of each ASMEmu opcode, ASMEmu doesn't
+// model timing at all, so these only follow what the operations would cost on
+// a simple stack machine, a multiply costing more than an add and so on
+// directives such as Label and DataC cost nothing, as they never execute
+var asmCycles = map[string]int{
+	"Nop": 1, "Halt": 1,
+
+	"PushI": 1, "PushF": 1, "PushD": 1,
+	"Duplicate": 1, "Exchange": 1, "Pop": 1,
+
+	"Add": 1, "Subtract": 1, "Negate": 1,
+	"Multiply": 3, "Divide": 10, "Remainder": 10,
+	"FAdd": 2, "FSubtract": 2, "FNegate": 2,
+	"FMultiply": 4, "FDivide": 15,
+	"ConvertF": 2, "ConvertI": 2,
+
+	"And": 1, "Or": 1, "Nand": 1, "Xor": 1,
+	"BTAnd": 1, "BTOr": 1, "BTXor": 1, "BTNot": 1, "BNegate": 1,
+	"ShiftLeft": 1, "ShiftRight": 1,
+
+	"LoadC": 2, "LoadI": 2, "LoadF": 2,
+	"StoreC": 2, "StoreI": 2, "StoreF": 2,
+	"Memtop": 1,
+
+	"Jump": 2, "JumpV": 2,
+	"JumpTrue": 2, "JumpFalse": 2, "JumpPos": 2, "JumpNeg": 2,
+	"JumpFZero": 2, "JumpFPos": 2, "JumpFNeg": 2,
+	"Call": 3, "CallV": 3, "Return": 3,
+
+	"Printf": 20, "PStack": 20,
+}
+
+// unknown opcodes are assumed to be as cheap as the simplest instructions
+const defaultCycles = 1
+
+// isDirective reports whether an opcode only lays out the program,
+// rather than being executed
+func (instruction asmInstruction) isDirective() bool {
+	return instruction.definesLabel() || strings.HasPrefix(instruction.opcode, "Data")
+}
+
+// asmStats counts the instructions of a program, statically, every
+// instruction counts once however many times it would run
+type asmStats struct {
+	instructions int
+	cycles       int
+	jumps        int
+	memoryOps    int
+
+	// size is an estimate of the program's size in bytes, taking a byte
+	// for each opcode, 4 for each operand, and the size of its data
+	size int
+
+	opcodes map[string]int
+}
+
+func (instruction asmInstruction) isJump() bool {
+	switch instruction.opcode {
+	case "Call", "CallV", "Return":
+		return true
+	}
+	return strings.HasPrefix(instruction.opcode, "Jump")
+}
+
+func (instruction asmInstruction) isMemoryOp() bool {
+	return strings.HasPrefix(instruction.opcode, "Load") ||
+		strings.HasPrefix(instruction.opcode, "Store")
+}
+
+// size estimates how many bytes an instruction or directive takes up
+func (instruction asmInstruction) size() int {
+	switch instruction.opcode {
+	case labelOp, dLabelOp:
+		return 0
+	case "DataC":
+		return 1
+	case "DataI", "DataD":
+		return 4
+	case "DataF":
+		return 8
+	case "DataS":
+		return len(strings.Trim(instruction.operand, `"`)) + 1
+	case "DataZ":
+		if size, err := strconv.Atoi(instruction.operand); err == nil {
+			return size
+		}
+		return 0
+	case "PushF":
+		return 9
+	}
+	if instruction.operand != "" {
+		return 5
+	}
+	return 1
+}
+
+func countAsm(instructions []asmInstruction) asmStats {
+	stats := asmStats{opcodes: map[string]int{}}
+	for _, instruction := range instructions {
+		stats.size += instruction.size()
+		if instruction.isDirective() {
+			continue
+		}
+		if instruction.isJump() {
+			stats.jumps++
+		}
+		if instruction.isMemoryOp() {
+			stats.memoryOps++
+		}
+		cycles, ok := asmCycles[instruction.opcode]
+		if !ok {
+			cycles = defaultCycles
+		}
+		stats.instructions++
+		stats.cycles += cycles
+		stats.opcodes[instruction.opcode]++
+	}
+	return stats
+}
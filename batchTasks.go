@@ -1,13 +1,15 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/fatih/color"
 )
@@ -170,9 +172,14 @@ func executeAll(count int,
 	outDir string, outExt string,
 	targetDir string,
 	cmd string, args []string) {
+	if wasInterrupted() {
+		return
+	}
 	files := getAllFiles(inDir)
 	files = filterFiles(files, inExt)
+	runProgress.expect(len(files))
 
+	before := snapshotProject()
 	var wg sync.WaitGroup
 	wg.Add(count)
 	for i := 0; i < count; i++ {
@@ -185,6 +192,18 @@ func executeAll(count int,
 			&wg, cmd, args)
 	}
 	wg.Wait()
+	if wasInterrupted() {
+		return
+	}
+
+	// tools only see their sandboxes, unless they go looking further afield
+	// with absolute or parent paths
+	if changed := snapshotProject().changedSince(before); len(changed) > 0 {
+		color.Red(cmd + " wrote outside of its sandboxes:")
+		for _, path := range changed {
+			fmt.Println(path)
+		}
+	}
 }
 
 func executeEach(files []os.FileInfo,
@@ -194,38 +213,91 @@ func executeEach(files []os.FileInfo,
 	wg *sync.WaitGroup,
 	cmd string, args []string) {
 
+	lane := acquireLane()
 	for _, file := range files {
-		srcPath := buildPath(inDir, file.Name())
-		targetPath := targetDir + "/"
-		completeArgs := append(args, srcPath)
-		if targetPath != "" {
-			completeArgs = append(completeArgs, targetPath)
+		if wasInterrupted() {
+			break
 		}
-
-		bytesToWrite := execute(cmd, completeArgs)
+		srcPath := buildPath(inDir, file.Name())
 
 		outputFilename := replaceExtension(file.Name(), outExt)
 		outputFilename = buildPath(outDir, outputFilename)
+		keep := func(run sandboxRun) {
+			toWrite := string(run.output)
+			toWrite = stripLines(toWrite, loggingMessage)
 
-		toWrite := string(bytesToWrite)
-		toWrite = stripLines(toWrite, loggingMessage)
+			writeFileAtomic(outputFilename, []byte(toWrite), 0777)
+			recordExit(outputFilename, run.exitCode)
+			runProgress.wrote()
+		}
 
-		bytesToWrite = []byte(toWrite)
-		ioutil.WriteFile(outputFilename, bytesToWrite, 0777)
+		started := time.Now()
+		run := executeSandboxed(cmd, args, srcPath, targetDir, keep)
+		recordSpan(file.Name(), spanKind(cmd), lane, started)
+		// a tool killed by the interrupt wrote nothing worth keeping
+		if run.interrupted {
+			break
+		}
+		if len(run.strays) > 0 {
+			color.Yellow(srcPath + ": " + cmd + " left scratch files behind: " +
+				strings.Join(run.strays, ", "))
+		}
 	}
+	releaseLane(lane)
 	wg.Done()
 }
 
-func execute(cmd string, args []string) []byte {
-	task := exec.Command(cmd, args...)
-	var stdout, stderr bytes.Buffer
-	task.Stdout, task.Stderr = &stdout, &stderr
-	if cmd == wine {
-		task.Stderr = nil
+// runEmulator runs the program at asmPath in the emulator, the same way
+// executeEach does for a run phase
+func runEmulator(asmPath string) ([]byte, int) {
+	run := executeSandboxed(wine, emulatorArgs, asmPath, "", nil)
+	return run.output, run.exitCode
+}
+
+// executeTimeout bounds how long each child spawned by execute may run,
+// zero leaves them unbounded
+var executeTimeout time.Duration
+
+// execute runs cmd in dir, under executeLimits, returning everything it
+// printed and its exit code, which is -1 if it couldn't be started or was killed
+func execute(dir, cmd string, args []string) ([]byte, int) {
+	ctx, cancel := context.WithCancel(interrupted)
+	defer cancel()
+	if executeTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, executeTimeout)
+		defer cancel()
 	}
+
+	limits := executeLimits
+	collected := newLimitedOutput(limits.outputMB, cancel)
+	wrapped, wrappedArgs := limits.wrap(cmd, args)
+	task := exec.CommandContext(ctx, wrapped, wrappedArgs...)
+	task.Dir = dir
+	inProcessGroup(task)
+	task.Stdout, task.Stderr = collected.stdoutWriter(), collected.stderrWriter()
+	// wine's stderr is its own chatter rather than the emulator's, but it is
+	// where it says it ran out of memory or files
+	collected.quietStderr = cmd == wine
+	// anything cmd started which left its process group may hold its output
+	// open long after cmd itself is killed, so stop waiting on it soon after
+	task.WaitDelay = time.Second
 	task.Run()
 
-	return append(stdout.Bytes(), stderr.Bytes()...)
+	output := collected.bytes()
+	if limit := limits.exceeded(task, collected); limit != "" {
+		// output cut off by the limit likely ends mid line
+		if len(output) > 0 && output[len(output)-1] != '\n' {
+			output = append(output, '\n')
+		}
+		output = append(output, []byte(limitMessage+limit+"\n")...)
+	} else if ctx.Err() == context.DeadlineExceeded {
+		output = append(output, []byte(timeoutMessage+"\n")...)
+	}
+	exitCode := -1
+	if task.ProcessState != nil {
+		exitCode = task.ProcessState.ExitCode()
+	}
+	return output, exitCode
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -234,6 +306,9 @@ func compareAllResults(count int,
 	resultDir, resExt,
 	expectDir, expExt,
 	refDir string) {
+	if wasInterrupted() {
+		return
+	}
 
 	testFiles := getAllFiles(refDir)
 	testFiles = filterOutFiles(testFiles, ".gitignore")
@@ -253,6 +328,8 @@ func compareAllResults(count int,
 		test := <-results
 		if test.result {
 			passed++
+		} else if test.limit != "" {
+			failed = append(failed, test.name+" (exceeded its limit on "+test.limit+")")
 		} else {
 			failed = append(failed, test.name)
 		}
@@ -278,6 +355,8 @@ func compareAllResults(count int,
 func compareEachResult(files []os.FileInfo, results chan testResult,
 	resultDir, resExt,
 	expectDir, expExt string) {
+	lane := acquireLane()
+	defer releaseLane(lane)
 	for _, file := range files {
 		resultFileName := replaceExtension(file.Name(), resExt)
 		resultFilePath := buildPath(resultDir, resultFileName)
@@ -285,9 +364,18 @@ func compareEachResult(files []os.FileInfo, results chan testResult,
 		expectFileName := replaceExtension(file.Name(), resExt)
 		expectFilePath := buildPath(expectDir, expectFileName)
 
+		started := time.Now()
+		passed := compareResult(resultFilePath, expectFilePath)
+		recordSpan(file.Name(), compareSpan, lane, started)
+
+		limit := ""
+		if !passed && exists(resultFilePath) {
+			limit = exceededLimit(readFile(resultFilePath))
+		}
 		results <- testResult{
 			name:   replaceExtension(file.Name(), ""),
-			result: compareResult(resultFilePath, expectFilePath)}
+			result: passed,
+			limit:  limit}
 	}
 }
 
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+)
+
+// a slowdown is only significant if Welch's t statistic reaches this,
+// roughly a one-sided 95% confidence for the handful of runs bench takes
+const significantT = 2.0
+
+// benchSamples are the wall-clock times, in seconds, of every timed run of a
+// single test, ASMEmu doesn't report how many cycles a program took, so wall
+// clock time is all there is to go on
+type benchSamples []float64
+
+func (samples benchSamples) median() float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append(benchSamples{}, samples...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+func (samples benchSamples) mean() float64 {
+	sum := 0.0
+	for _, sample := range samples {
+		sum += sample
+	}
+	return sum / float64(len(samples))
+}
+
+// variance is the sample variance, with Bessel's correction
+func (samples benchSamples) variance() float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	mean := samples.mean()
+	sum := 0.0
+	for _, sample := range samples {
+		sum += (sample - mean) * (sample - mean)
+	}
+	return sum / float64(len(samples)-1)
+}
+
+// slowerThan reports whether samples are significantly slower than baseline,
+// by Welch's t-test, and by more than threshold, a fraction of the baseline's
+// median, so that tiny but consistent differences aren't flagged
+func (samples benchSamples) slowerThan(baseline benchSamples, threshold float64) bool {
+	if len(samples) < 2 || len(baseline) < 2 {
+		return false
+	}
+	if samples.median() <= baseline.median()*(1+threshold) {
+		return false
+	}
+	spread := math.Sqrt(samples.variance()/float64(len(samples)) +
+		baseline.variance()/float64(len(baseline)))
+	if spread == 0 {
+		return true
+	}
+	return (samples.mean()-baseline.mean())/spread >= significantT
+}
+
+func readBenchmarks(path string) map[string]benchSamples {
+	benchmarks := map[string]benchSamples{}
+	contents := readFile(path)
+	if contents != "" {
+		json.Unmarshal([]byte(contents), &benchmarks)
+	}
+	return benchmarks
+}
+
+func writeBenchmarks(path string, benchmarks map[string]benchSamples) {
+	contents, err := json.MarshalIndent(benchmarks, "", "\t")
+	crashOnError(err)
+	mkdirIfNotExist(gtrDir)
+	crashOnError(writeFileAtomic(path, contents, 0666))
+}
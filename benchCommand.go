@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// benchCommand times the run phase of every test in a set, which matches
+// targets if any are given, flagging those significantly slower than the
+// baseline, tests are run one at a time so that they don't skew each other
+func benchCommand(flags benchFlags, targets []string) {
+	for _, target := range targets {
+		if _, err := filepath.Match(target, ""); err != nil {
+			color.Magenta(target + " is not a valid glob")
+			os.Exit(1)
+		}
+	}
+	executeTimeout = flags.timeout
+	executeLimits = flags.limits
+
+	outputs := []testOutput{}
+	unbuilt := 0
+	for _, testname := range testNames(flags.testSet) {
+		if len(targets) > 0 && !matchesAny(testname, targets) {
+			continue
+		}
+		output := testOutput{testname, flags.testSet, asm}
+		if !exists(output.resultPath()) {
+			unbuilt++
+			continue
+		}
+		outputs = append(outputs, output)
+	}
+	if unbuilt > 0 {
+		color.Yellow(fmt.Sprint(unbuilt, " tests haven't been built, ") +
+			"run gtr test first to include them")
+	}
+	if len(outputs) == 0 {
+		color.Magenta("there is nothing to benchmark")
+		os.Exit(1)
+		return
+	}
+
+	baseline := readBenchmarks(benchBaselineFile)
+	current := map[string]benchSamples{}
+	slower := []string{}
+	red := color.New(color.FgRed)
+	color.Cyan(fmt.Sprintf("BENCHMARKING %d RUNS OF %d TESTS...", flags.runs, len(outputs)))
+	fmt.Printf("%-24s %12s %12s %12s %10s\n", "", "median", "stddev", "baseline", "change")
+	for _, output := range outputs {
+		key := metricsKey(testOutput{output.testname, output.testSet, run})
+		samples, ok := benchRuns(output.resultPath(), flags.runs)
+		if !ok {
+			red.Printf("%-24s failed or timed out, skipping\n", output.testname)
+			continue
+		}
+		current[key] = samples
+
+		line := fmt.Sprintf("%-24s %10.2fms %10.2fms", output.testname,
+			1000*samples.median(), 1000*math.Sqrt(samples.variance()))
+		before, ok := baseline[key]
+		if !ok {
+			fmt.Println(line)
+			continue
+		}
+		line += fmt.Sprintf(" %10.2fms %+9.1f%%", 1000*before.median(),
+			100*(samples.median()-before.median())/before.median())
+		if samples.slowerThan(before, flags.threshold) {
+			slower = append(slower, output.testname)
+			red.Println(line + "  slower")
+		} else {
+			fmt.Println(line)
+		}
+	}
+
+	if flags.save {
+		for key, samples := range current {
+			baseline[key] = samples
+		}
+		writeBenchmarks(benchBaselineFile, baseline)
+		fmt.Println("saved", len(current), "timings as the baseline")
+	}
+
+	fmt.Println()
+	if len(slower) == 0 {
+		color.Green("no significant slowdowns")
+		return
+	}
+	color.Red(fmt.Sprint(len(slower), " tests are significantly slower than the baseline:"))
+	for _, testname := range slower {
+		fmt.Println(testname)
+	}
+	os.Exit(1)
+}
+
+// benchRuns runs the program at asmPath once to warm up, then times it runs
+// more times, giving up if any of them fail
+func benchRuns(asmPath string, runs int) (benchSamples, bool) {
+	if output, exitCode := runEmulator(asmPath); exitCode != 0 || timedOut(string(output)) {
+		return nil, false
+	}
+	samples := make(benchSamples, 0, runs)
+	for i := 0; i < runs; i++ {
+		start := time.Now()
+		output, exitCode := runEmulator(asmPath)
+		elapsed := time.Since(start)
+		if exitCode != 0 || timedOut(string(output)) {
+			return nil, false
+		}
+		samples = append(samples, elapsed.Seconds())
+	}
+	return samples, true
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// snapshot is a single historical build in a bisect, named for whatever it
+// was stored as
+type snapshot struct {
+	name  string
+	tools toolchain
+}
+
+// bisectCommand binary searches an ordered directory of snapshots for the
+// first in which a test's output stops matching its expectation
+func bisectCommand(flags bisectFlags, testname string) {
+	output := bisectOutput(flags, testname)
+	if !exists(flags.jars) {
+		color.Magenta(flags.jars + " does not exist")
+		os.Exit(1)
+		return
+	}
+	executeTimeout = flags.timeout
+	executeLimits = flags.limits
+
+	workDir, err := ioutil.TempDir("", tempPrefix)
+	crashOnError(err)
+	defer os.RemoveAll(workDir)
+
+	snapshots := listSnapshots(flags.jars, bisectedTool(flags.testSet), workDir)
+	if len(snapshots) < 2 {
+		color.Magenta(flags.jars + " needs at least two snapshots to bisect between")
+		os.RemoveAll(workDir)
+		os.Exit(1)
+		return
+	}
+
+	step := 0
+	passes := func(i int) bool {
+		step++
+		passed := snapshotPasses(snapshots[i].tools, output, buildPath(workDir, "run", fmt.Sprint(step)))
+		verdict := color.New(color.FgGreen).Sprint("good")
+		if !passed {
+			verdict = color.New(color.FgRed).Sprint("bad")
+		}
+		fmt.Printf("%-40s %s\n", snapshots[i].name, verdict)
+		return passed
+	}
+
+	color.Cyan(fmt.Sprintf("BISECTING %d SNAPSHOTS...", len(snapshots)))
+	good, bad := 0, len(snapshots)-1
+	if !passes(good) {
+		color.Magenta(output.String() + " already fails in the oldest snapshot, " + snapshots[good].name)
+		os.RemoveAll(workDir)
+		os.Exit(1)
+		return
+	}
+	if passes(bad) {
+		color.Magenta(output.String() + " still passes in the newest snapshot, " + snapshots[bad].name)
+		os.RemoveAll(workDir)
+		os.Exit(1)
+		return
+	}
+	for bad-good > 1 {
+		middle := (good + bad) / 2
+		if passes(middle) {
+			good = middle
+		} else {
+			bad = middle
+		}
+	}
+
+	fmt.Println()
+	color.Red("first bad snapshot: " + snapshots[bad].name)
+	fmt.Println("last good snapshot:", snapshots[good].name)
+	fmt.Println("found in", step, "steps")
+}
+
+// bisectOutput is the output a bisect checks, which must have an expectation
+// to check it against
+func bisectOutput(flags bisectFlags, testname string) testOutput {
+	output := testOutput{testname, flags.testSet, flags.phase}
+	if !exists(output.sourcePath()) {
+		color.Magenta(output.sourcePath() + " does not exist")
+		os.Exit(1)
+	}
+	if !exists(output.expectPath()) {
+		color.Magenta("there is no expectation set for " + output.String())
+		os.Exit(1)
+	}
+	return output
+}
+
+// bisectedTool is the jar a bisect swaps out, the last tool in the set's
+// pipeline, so that the optimizer is bisected for the optimizer set
+func bisectedTool(testSet string) string {
+	tools := toolsFor(testSet, build)
+	return tools[len(tools)-1]
+}
+
+// listSnapshots finds every snapshot in dir, oldest first, by name
+// subdirectories are whole toolchains, while loose jars are snapshots of
+// just the tool named jar, run alongside the rest of bin
+func listSnapshots(dir, jar, workDir string) []snapshot {
+	files := getAllFiles(dir)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+
+	snapshots := []snapshot{}
+	for _, file := range files {
+		path := buildPath(dir, file.Name())
+		if file.IsDir() {
+			snapshots = append(snapshots, snapshot{file.Name(), toolchain{path}})
+			continue
+		}
+		if !strings.HasSuffix(file.Name(), ".jar") {
+			continue
+		}
+
+		toolsDir := buildPath(workDir, "snapshots", file.Name())
+		mkdirIfNotExist(toolsDir)
+		for _, tool := range []string{compilerName, codegeneratorName, optimizerName} {
+			if exists(buildPath(binDir, tool)) {
+				crashOnError(copyFile(buildPath(binDir, tool), buildPath(toolsDir, tool)))
+			}
+		}
+		crashOnError(copyFile(path, buildPath(toolsDir, jar)))
+		snapshots = append(snapshots, snapshot{file.Name(), toolchain{toolsDir}})
+	}
+	return snapshots
+}
+
+// snapshotPasses runs a test through tools, in a fresh directory under
+// workDir, and reports whether the output of its phase matches expectation
+func snapshotPasses(tools toolchain, output testOutput, workDir string) bool {
+	actual := runPipeline(tools, output.testSet, output.sourcePath(), workDir).phase(output.phase)
+	return resultPaths(workDir, output).Replace(actual) == readFile(output.expectPath())
+}
+
+// resultPaths maps the paths runPipeline used under workDir back to the ones
+// gtr test would have, the copy of the source to the original and each
+// phase's directory to the one in result, longest first so that
+// optimizer-standalone isn't taken for optimizer
+func resultPaths(workDir string, output testOutput) *strings.Replacer {
+	paths := map[string]string{
+		buildPath(workDir, "src", filepath.Base(output.sourcePath())): output.sourcePath(),
+	}
+	for _, phase := range []string{build, asm, run} {
+		for _, testSet := range testSets {
+			paths[buildPath(workDir, phase, testSet)] = result.dir(phase, testSet)
+		}
+	}
+	pipelinePaths := []string{}
+	for path := range paths {
+		pipelinePaths = append(pipelinePaths, path)
+	}
+	sort.Slice(pipelinePaths, func(i, j int) bool {
+		return len(pipelinePaths[i]) > len(pipelinePaths[j])
+	})
+	pairs := []string{}
+	for _, path := range pipelinePaths {
+		pairs = append(pairs, path, paths[path])
+	}
+	return strings.NewReplacer(pairs...)
+}
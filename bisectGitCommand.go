@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+var firstBadPattern = regexp.MustCompile(`(?m)^([0-9a-f]{7,40}) is the first bad commit`)
+
+// bisectGitCommand drives git bisect in the repository a tool is built from,
+// rebuilding the tool into bin at each step and running a single test to
+// decide whether the commit is good or bad
+func bisectGitCommand(flags bisectGitFlags, testname string) {
+	output := bisectOutput(flags.bisectFlags, testname)
+	if _, err := git(flags.repo, "rev-parse", "--git-dir"); err != nil {
+		color.Magenta(flags.repo + " is not a git repository")
+		os.Exit(1)
+		return
+	}
+	if status, _ := git(flags.repo, "status", "--porcelain", "--untracked-files=no"); status != "" {
+		color.Magenta(flags.repo + " has uncommitted changes, commit or stash them first")
+		os.Exit(1)
+		return
+	}
+	executeTimeout = flags.timeout
+	executeLimits = flags.limits
+	handleInterrupts()
+
+	tool := bisectedTool(flags.testSet)
+	if flags.jar == "" {
+		flags.jar = tool
+	}
+
+	workDir, err := ioutil.TempDir("", tempPrefix)
+	crashOnError(err)
+	culprit, err := gitBisect(flags, output, tool, workDir)
+	os.RemoveAll(workDir)
+	if err != nil {
+		color.Magenta(err.Error())
+		os.Exit(1)
+		return
+	}
+
+	fmt.Println()
+	color.Red("first bad commit:")
+	fmt.Print(culprit)
+}
+
+// gitBisect runs the bisect itself, leaving the repository, and the tool in
+// bin, the way they were found however it ends, short of being interrupted
+// a second time
+func gitBisect(flags bisectGitFlags, output testOutput, tool, workDir string) (string, error) {
+	binJar := buildPath(binDir, tool)
+	savedJar := buildPath(workDir, tool)
+	if exists(binJar) {
+		if err := copyFile(binJar, savedJar); err != nil {
+			return "", err
+		}
+	}
+	defer func() {
+		if exists(savedJar) {
+			copyFile(savedJar, binJar)
+		} else {
+			os.Remove(binJar)
+		}
+	}()
+
+	if log, err := git(flags.repo, "bisect", "start", flags.bad, flags.good); err != nil {
+		return "", errors.New("git bisect failed to start:\n" + log)
+	}
+	defer git(flags.repo, "bisect", "reset")
+
+	color.Cyan("BISECTING " + flags.good + ".." + flags.bad + "...")
+	for step := 1; ; step++ {
+		commit, _ := git(flags.repo, "log", "-1", "--format=%h %s")
+		commit = strings.TrimSpace(commit)
+
+		// whatever was cut short by an interrupt decides nothing
+		buildLog, buildErr := buildTool(flags)
+		if wasInterrupted() {
+			return "", interruptedBisect(flags, binJar)
+		}
+		verdict := "skip"
+		shown := color.New(color.FgYellow).Sprint("didn't build, skipped")
+		if buildErr != nil {
+			if flags.verbose && buildLog != "" {
+				shown += "\n" + strings.TrimSuffix(buildLog, "\n")
+			}
+		} else if err := copyFile(buildPath(flags.repo, flags.jar), binJar); err != nil {
+			shown = color.New(color.FgYellow).Sprint(flags.jar + " wasn't built, skipped")
+		} else if snapshotPasses(binToolchain, output, buildPath(workDir, "run", fmt.Sprint(step))) {
+			verdict = "good"
+			shown = color.New(color.FgGreen).Sprint(verdict)
+		} else {
+			verdict = "bad"
+			shown = color.New(color.FgRed).Sprint(verdict)
+		}
+		if wasInterrupted() {
+			return "", interruptedBisect(flags, binJar)
+		}
+		fmt.Printf("%-60s %s\n", commit, shown)
+
+		log, err := git(flags.repo, "bisect", verdict)
+		if match := firstBadPattern.FindStringSubmatch(log); match != nil {
+			culprit, _ := git(flags.repo, "show", "--stat", "--format=medium", match[1])
+			return culprit, nil
+		}
+		if strings.Contains(log, "only 'skip'ped commits left") {
+			return "", errors.New("the first bad commit couldn't be found, " +
+				"as commits which didn't build were skipped:\n" + log)
+		}
+		if err != nil {
+			return "", errors.New("git bisect " + verdict + " failed:\n" + log)
+		}
+	}
+}
+
+// interruptedBisect is the error a bisect stops with when it is interrupted,
+// by which time the deferred clean up has put everything back
+func interruptedBisect(flags bisectGitFlags, binJar string) error {
+	return errors.New("interrupted, " + flags.repo + " and " + binJar +
+		" are back the way they were found")
+}
+
+// buildTool runs the build command in the repository, returning its output
+func buildTool(flags bisectGitFlags) (string, error) {
+	task := exec.CommandContext(interrupted, "sh", "-c", flags.build)
+	task.Dir = flags.repo
+	inProcessGroup(task)
+	log, err := task.CombinedOutput()
+	return string(log), err
+}
+
+// git runs a git command in repo, returning everything it printed
+func git(repo string, args ...string) (string, error) {
+	task := exec.Command("git", append([]string{"-C", repo}, args...)...)
+	log, err := task.CombinedOutput()
+	return string(log), err
+}
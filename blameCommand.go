@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+
+	"github.com/fatih/color"
+)
+
+func blameCommand(flags blameFlags, testname string) {
+	path := testPath(testname, flags.asm)
+	if !exists(path) {
+		color.Magenta(path + " does not exist")
+		os.Exit(1)
+		return
+	}
+
+	sets := []string{codegenerator, optimizer, compiler}
+	if flags.asm {
+		sets = []string{optimizerStandalone}
+	}
+
+	records := readProvenance()
+	for _, testSet := range sets {
+		for _, phase := range phases {
+			output := testOutput{testname, testSet, phase}
+			if output.expectPath() == "" || !exists(output.expectPath()) {
+				continue
+			}
+			color.Cyan(testSet + " " + phase + "...")
+			printProvenance(records, output.expectPath())
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// compareToolchainsCommand runs the same tests through two toolchains and
+// shows every output which differs between them, expectations play no part
+func compareToolchainsCommand(flags compareToolchainsFlags, targets []string) {
+	for _, dir := range []string{flags.a, flags.b} {
+		if !exists(dir) {
+			color.Magenta(dir + " does not exist")
+			os.Exit(1)
+			return
+		}
+	}
+	for _, target := range targets {
+		if _, err := filepath.Match(target, ""); err != nil {
+			color.Magenta(target + " is not a valid glob")
+			os.Exit(1)
+		}
+	}
+	executeTimeout = flags.timeout
+	executeLimits = flags.limits
+
+	workDir, err := ioutil.TempDir("", tempPrefix)
+	crashOnError(err)
+	defer os.RemoveAll(workDir)
+
+	// both toolchains read the same sources, so that any paths they print
+	// are the same
+	sets := flags.testSets()
+	srcDir := buildPath(workDir, "src")
+	mkdirIfNotExist(srcDir)
+	names := []string{}
+	for _, testname := range testNames(sets[0]) {
+		if len(targets) > 0 && !matchesAny(testname, targets) {
+			continue
+		}
+		source := []byte(readFile(testPath(testname, flags.asm)))
+		err := ioutil.WriteFile(buildPath(srcDir, testname+sourceExt(sets[0])), source, 0777)
+		crashOnError(err)
+		names = append(names, testname)
+	}
+	if len(names) == 0 {
+		color.Magenta("there are no tests to compare")
+		os.RemoveAll(workDir)
+		os.Exit(1)
+		return
+	}
+
+	aDir, bDir := buildPath(workDir, "a"), buildPath(workDir, "b")
+	color.Cyan("RUNNING " + flags.a + "...")
+	batchPipeline(toolchain{flags.a}, flags.threads, sets, srcDir, aDir)
+	color.Cyan("RUNNING " + flags.b + "...")
+	batchPipeline(toolchain{flags.b}, flags.threads, sets, srcDir, bDir)
+
+	differing := []testOutput{}
+	diffs := map[testOutput]diff{}
+	counts := map[string]int{}
+	for _, testname := range names {
+		for _, testSet := range sets {
+			aOutput := readPipelineOutput(testSet, aDir, testname)
+			bOutput := readPipelineOutput(testSet, bDir, testname)
+			for _, phase := range []string{build, asm, run} {
+				from := strings.Replace(aOutput.phase(phase), aDir, "", -1)
+				to := strings.Replace(bOutput.phase(phase), bDir, "", -1)
+				if from == to {
+					continue
+				}
+				output := testOutput{testname, testSet, phase}
+				differing = append(differing, output)
+				diffs[output] = diffText(flags.a, flags.b, from, to)
+				counts[testSet+" "+phase]++
+			}
+		}
+	}
+
+	total := len(names) * len(sets) * 3
+	if len(differing) > 0 {
+		closePager := startPager()
+		for _, output := range differing {
+			color.Cyan("==> " + output.testname + "  " + output.testSet + "  " + output.phase)
+			printDiff(diffs[output], flags.diffStyle)
+			fmt.Println()
+		}
+		closePager()
+
+		color.Yellow("differing outputs...")
+		for _, testSet := range sets {
+			for _, phase := range []string{build, asm, run} {
+				if counts[testSet+" "+phase] > 0 {
+					fmt.Printf("%-22s %-6s %d\n", testSet, phase, counts[testSet+" "+phase])
+				}
+			}
+		}
+	}
+	green := color.New(color.FgGreen)
+	green.Println("identical: [", total-len(differing), "/", total, "]")
+	if len(differing) > 0 {
+		os.RemoveAll(workDir)
+		os.Exit(1)
+	}
+}
@@ -7,7 +7,18 @@ const (
 
 	expectDir = "./expect"
 	resultDir = "./result"
-	backupDir = "./.backup"
+	gtrDir    = "./.gtr"
+
+	knownFailuresFile = gtrDir + "/known-failures"
+	historyDir        = gtrDir + "/history"
+	journalFile       = "journal.json"
+	runRecordFile     = gtrDir + "/last-run.json"
+	metricsFile       = gtrDir + "/metrics.json"
+	benchBaselineFile = gtrDir + "/bench-baseline.json"
+
+	provenanceFile        = expectDir + "/provenance.json"
+	metricsBaselineFile   = expectDir + "/metrics-baseline.json"
+	toolchainManifestFile = expectDir + "/toolchain.json"
 
 	pikaExt = ".pika"
 	asmExt  = ".asm"
@@ -24,6 +35,11 @@ const (
 	codegenerator       = "codegenerator"
 	optimizer           = "optimizer"
 	optimizerStandalone = "optimizer-standalone"
+	unoptimized         = "unoptimized"
+
+	unifiedDiff    = "unified"
+	sideBySideDiff = "side-by-side"
+	wordDiff       = "words"
 
 	open              = "open"
 	xdgOpen           = "xdg-open"
@@ -31,9 +47,17 @@ const (
 	compilerName      = "pika-compiler.jar"
 	codegeneratorName = "pika-codegen.jar"
 	optimizerName     = "pika-optimizer.jar"
+	emulatorName      = "ASMEmu.exe"
 	wine              = "wine"
 
 	loggingMessage = "logging.PikaLogger log"
+	timeoutMessage = "gtr: timed out"
+	limitMessage   = "gtr: exceeded its limit on "
+
+	tempPrefix = "gtr-"
+
+	// a run printing more than this is almost certainly stuck in a loop
+	defaultMaxOutputMB = 64
 
 	basicAsmFile  = "Halt\n"
 	basicPikaFile = "exec {\n\n}\n"
@@ -42,10 +66,10 @@ const (
 // these are vars, but just as a technical restriction
 // they should be considered constants
 var (
-	compilerArgs      = []string{"-ea", "-jar", binDir + "/" + compilerName}
-	codegeneratorArgs = []string{"-ea", "-jar", binDir + "/" + codegeneratorName}
-	optimizerArgs     = []string{"-ea", "-jar", binDir + "/" + optimizerName}
-	emulatorArgs      = []string{binDir + "/ASMEmu.exe"}
+	compilerArgs      = binToolchain.compilerArgs()
+	codegeneratorArgs = binToolchain.codegeneratorArgs()
+	optimizerArgs     = binToolchain.optimizerArgs()
+	emulatorArgs      = []string{binDir + "/" + emulatorName}
 
 	// TODO reoptimize
 	result = testDirTree{
@@ -7,26 +7,29 @@ import (
 )
 
 func createCommand(flags createFlags, arg string) {
-	var path string
-	var contents []byte
+	path := testPath(arg, flags.asm)
 
+	var contents []byte
 	if flags.asm {
-		filename := arg + asmExt
-		path = buildPath(asmDir, filename)
 		contents = []byte(basicAsmFile)
 	} else {
-		filename := arg + pikaExt
-		path = buildPath(pikaDir, filename)
 		contents = []byte(basicPikaFile)
 	}
 
-	if !exists(path) {
-		ioutil.WriteFile(path, contents, 0777)
-	} else {
-		color.Magenta(path + " already exists")
-	}
+	createTest(path, contents)
 
 	if flags.open {
 		openDefaultEditor(path)
 	}
 }
+
+// createTest writes contents out as a new test at path,
+// an existing test is never overwritten
+func createTest(path string, contents []byte) bool {
+	if exists(path) {
+		color.Magenta(path + " already exists")
+		return false
+	}
+	ioutil.WriteFile(path, contents, 0777)
+	return true
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+// lines of unchanged context kept around each change
+const diffContext = 3
+
+// edit is a single line, or word, of a diff
+// op is ' ' where it is in both sides, '-' where it was removed, and '+' where
+// it was added
+type edit struct {
+	op   byte
+	text string
+}
+
+// diff is the difference between the files at from and to
+type diff struct {
+	from string
+	to   string
+
+	edits []edit
+
+	// newlineOnly is set when the files differ in nothing but whether they
+	// end with a newline
+	newlineOnly bool
+}
+
+// hunk is a run of edits worth showing, along with the line of each side it
+// starts at
+type hunk struct {
+	fromLine int
+	toLine   int
+	edits    []edit
+}
+
+// makeDiff compares the file at fromPath with the file at toPath, either may
+// be missing, or os.DevNull, which both compare as empty
+func makeDiff(fromPath, toPath string) diff {
+	return diffText(fromPath, toPath, readFile(fromPath), readFile(toPath))
+}
+
+// diffText compares two texts, labelled fromName and toName
+func diffText(fromName, toName, from, to string) diff {
+	result := diff{from: fromName, to: toName}
+	result.edits = myers(splitDiffLines(from), splitDiffLines(to))
+	result.newlineOnly = from != to && !result.changed()
+	return result
+}
+
+func splitDiffLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// changed reports whether any line differs
+func (d diff) changed() bool {
+	for _, e := range d.edits {
+		if e.op != ' ' {
+			return true
+		}
+	}
+	return false
+}
+
+// myers finds the shortest edit script turning a into b, as described in
+// "An O(ND) Difference Algorithm and Its Variations", Myers 1986
+func myers(a, b []string) []edit {
+	// the common prefix and suffix are cheap to find and needn't be searched
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]edit, 0, len(a)+len(b))
+	for _, text := range a[:prefix] {
+		edits = append(edits, edit{' ', text})
+	}
+	edits = append(edits, myersMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, text := range a[len(a)-suffix:] {
+		edits = append(edits, edit{' ', text})
+	}
+	return edits
+}
+
+// myersMiddle finds the edits between a and b in linear space, by finding
+// the middle snake of the shortest edit script, and recursing either side
+func myersMiddle(a, b []string) []edit {
+	edits := []edit{}
+	if len(a) == 0 || len(b) == 0 {
+		for _, text := range a {
+			edits = append(edits, edit{'-', text})
+		}
+		for _, text := range b {
+			edits = append(edits, edit{'+', text})
+		}
+		return edits
+	}
+
+	// either side has fewer edits than the whole, and myers strips what they
+	// have in common, so this always gets down to one side being empty
+	x, y, u, v := middleSnake(a, b)
+	edits = append(edits, myers(a[:x], b[:y])...)
+	for _, text := range a[x:u] {
+		edits = append(edits, edit{' ', text})
+	}
+	return append(edits, myers(a[u:], b[v:])...)
+}
+
+// middleSnake finds the snake, from (x, y) to (u, v), in the middle of the
+// shortest edit script turning a into b, by searching forwards from the start
+// and backwards from the end at once until the two meet
+func middleSnake(a, b []string) (int, int, int, int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	max := (n + m + 1) / 2
+
+	// forward[offset+k] is the furthest x reached along diagonal k from the
+	// start, backward[offset+k] is the furthest reached from the end, counted
+	// back from the end, along diagonal k of the reversed texts
+	offset := max + 1
+	forward := make([]int, 2*max+3)
+	backward := make([]int, 2*max+3)
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			// diagonal k from the start is diagonal delta-k from the end
+			reverseK := delta - k
+			if delta%2 != 0 && reverseK >= -(d-1) && reverseK <= d-1 &&
+				x+backward[offset+reverseK] >= n {
+				return startX, startY, x, y
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+
+			forwardK := delta - k
+			if delta%2 == 0 && forwardK >= -d && forwardK <= d &&
+				forward[offset+forwardK]+x >= n {
+				return n - x, m - y, n - startX, m - startY
+			}
+		}
+	}
+	panic("the searches from either end of a diff never met")
+}
+
+// hunks groups the changes of d, along with context lines of unchanged text
+// around each, merging any which overlap
+func (d diff) hunks(context int) []hunk {
+	// [start, end) of the edits in each hunk
+	ranges := [][2]int{}
+	for i, e := range d.edits {
+		if e.op == ' ' {
+			continue
+		}
+		start, end := i-context, i+context+1
+		if start < 0 {
+			start = 0
+		}
+		if end > len(d.edits) {
+			end = len(d.edits)
+		}
+		if last := len(ranges) - 1; last >= 0 && start <= ranges[last][1] {
+			ranges[last][1] = end
+		} else {
+			ranges = append(ranges, [2]int{start, end})
+		}
+	}
+
+	hunks := []hunk{}
+	fromLine, toLine := 1, 1
+	next := 0
+	for _, r := range ranges {
+		for ; next < r[0]; next++ {
+			fromLine++
+			toLine++
+		}
+		hunks = append(hunks, hunk{fromLine, toLine, d.edits[r[0]:r[1]]})
+		for ; next < r[1]; next++ {
+			if d.edits[next].op != '+' {
+				fromLine++
+			}
+			if d.edits[next].op != '-' {
+				toLine++
+			}
+		}
+	}
+	return hunks
+}
+
+func (h hunk) header() string {
+	fromCount, toCount := 0, 0
+	for _, e := range h.edits {
+		if e.op != '+' {
+			fromCount++
+		}
+		if e.op != '-' {
+			toCount++
+		}
+	}
+	fromLine, toLine := h.fromLine, h.toLine
+	if fromCount == 0 {
+		fromLine--
+	}
+	if toCount == 0 {
+		toLine--
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", fromLine, fromCount, toLine, toCount)
+}
+
+// changeBlocks splits the edits of a hunk into runs of unchanged lines, and
+// runs of changes with the removed lines kept apart from the added ones
+func (h hunk) changeBlocks(each func(same, removed, added []string)) {
+	for i := 0; i < len(h.edits); {
+		same, removed, added := []string{}, []string{}, []string{}
+		for ; i < len(h.edits) && h.edits[i].op == ' '; i++ {
+			same = append(same, h.edits[i].text)
+		}
+		for ; i < len(h.edits) && h.edits[i].op != ' '; i++ {
+			if h.edits[i].op == '-' {
+				removed = append(removed, h.edits[i].text)
+			} else {
+				added = append(added, h.edits[i].text)
+			}
+		}
+		each(same, removed, added)
+	}
+}
+
+// printDiff shows d in the given style, one of unifiedDiff, sideBySideDiff,
+// or wordDiff
+func printDiff(d diff, style string) {
+	if d.newlineOnly {
+		color.Yellow("the files differ only in whether they end with a newline")
+		return
+	}
+	if !d.changed() {
+		return
+	}
+
+	lightBlue := color.New(color.FgHiBlue)
+	fmt.Println(color.New(color.Bold).Sprint("--- " + d.from))
+	fmt.Println(color.New(color.Bold).Sprint("+++ " + d.to))
+	width := terminalWidth()
+	for _, h := range d.hunks(diffContext) {
+		lightBlue.Println(h.header())
+		switch style {
+		case sideBySideDiff:
+			printSideBySide(h, width)
+		case wordDiff:
+			printWordDiff(h)
+		default:
+			printUnified(h)
+		}
+	}
+}
+
+func printUnified(h hunk) {
+	red := color.New(color.FgRed)
+	green := color.New(color.FgGreen)
+	for _, e := range h.edits {
+		switch e.op {
+		case '+':
+			green.Println("+" + e.text)
+		case '-':
+			red.Println("-" + e.text)
+		default:
+			fmt.Println(" " + e.text)
+		}
+	}
+}
+
+// printSideBySide shows the removed lines of a hunk on the left, and the
+// added ones on the right, in two columns which fit within width
+func printSideBySide(h hunk, width int) {
+	column := (width - 3) / 2
+	if column < 10 {
+		column = 10
+	}
+	red := color.New(color.FgRed)
+	green := color.New(color.FgGreen)
+	h.changeBlocks(func(same, removed, added []string) {
+		for _, line := range same {
+			fmt.Println(strings.TrimRight(fitColumn(line, column)+"   "+fitColumn(line, column), " "))
+		}
+		for i := 0; i < len(removed) || i < len(added); i++ {
+			left, right, marker := "", "", " | "
+			if i < len(removed) {
+				left = removed[i]
+			} else {
+				marker = " > "
+			}
+			if i < len(added) {
+				right = added[i]
+			} else {
+				marker = " < "
+			}
+			fmt.Println(red.Sprint(fitColumn(left, column)) + marker +
+				green.Sprint(fitColumn(right, column)))
+		}
+	})
+}
+
+// fitColumn pads or truncates line to exactly width characters
+func fitColumn(line string, width int) string {
+	line = strings.Replace(line, "\t", "    ", -1)
+	length := utf8.RuneCountInString(line)
+	if length > width {
+		runes := []rune(line)
+		return string(runes[:width-1]) + "…"
+	}
+	return line + strings.Repeat(" ", width-length)
+}
+
+var wordPattern = regexp.MustCompile(`\w+|\s+|.`)
+
+// printWordDiff shows a hunk as a unified diff, but where a removed line
+// pairs up with an added one, the words which changed between them are
+// highlighted
+func printWordDiff(h hunk) {
+	red := color.New(color.FgRed)
+	green := color.New(color.FgGreen)
+	redWord := color.New(color.FgRed, color.ReverseVideo)
+	greenWord := color.New(color.FgGreen, color.ReverseVideo)
+	h.changeBlocks(func(same, removed, added []string) {
+		for _, line := range same {
+			fmt.Println(" " + line)
+		}
+		paired := len(removed)
+		if len(added) < paired {
+			paired = len(added)
+		}
+		for i, line := range removed {
+			if i >= paired {
+				red.Println("-" + line)
+				continue
+			}
+			words := myers(wordPattern.FindAllString(line, -1),
+				wordPattern.FindAllString(added[i], -1))
+			fmt.Println(red.Sprint("-") + highlightWords(words, '-', red, redWord))
+		}
+		for i, line := range added {
+			if i >= paired {
+				green.Println("+" + line)
+				continue
+			}
+			words := myers(wordPattern.FindAllString(removed[i], -1),
+				wordPattern.FindAllString(line, -1))
+			fmt.Println(green.Sprint("+") + highlightWords(words, '+', green, greenWord))
+		}
+	})
+}
+
+// highlightWords renders one side of a word diff, op picks the side
+func highlightWords(words []edit, op byte, plain, highlight *color.Color) string {
+	var line strings.Builder
+	for _, word := range words {
+		switch word.op {
+		case ' ':
+			line.WriteString(plain.Sprint(word.text))
+		case op:
+			line.WriteString(highlight.Sprint(word.text))
+		}
+	}
+	return line.String()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// showEdits writes each edit as its op followed by its text
+func showEdits(edits []edit) []string {
+	shown := []string{}
+	for _, e := range edits {
+		shown = append(shown, string(e.op)+e.text)
+	}
+	return shown
+}
+
+func TestDiffText(t *testing.T) {
+	tests := []struct {
+		name        string
+		from, to    string
+		edits       []string
+		newlineOnly bool
+	}{
+		{"both empty", "", "", []string{}, false},
+		{"from empty", "", "a\nb\n", []string{"+a", "+b"}, false},
+		{"to empty", "a\nb\n", "", []string{"-a", "-b"}, false},
+		{"same", "a\nb\n", "a\nb\n", []string{" a", " b"}, false},
+		{"prefix added", "b\nc\n", "a\nb\nc\n", []string{"+a", " b", " c"}, false},
+		{"prefix removed", "a\nb\nc\n", "b\nc\n", []string{"-a", " b", " c"}, false},
+		{"suffix added", "a\nb\n", "a\nb\nc\n", []string{" a", " b", "+c"}, false},
+		{"suffix removed", "a\nb\nc\n", "a\nb\n", []string{" a", " b", "-c"}, false},
+		{"middle changed", "a\nb\nc\n", "a\nx\nc\n", []string{" a", "-b", "+x", " c"}, false},
+		{"nothing in common", "a\nb\n", "c\nd\n", []string{"-a", "-b", "+c", "+d"}, false},
+		{"interleaved", "a\nb\nc\nd\n", "b\nx\nd\ny\n", []string{"-a", " b", "-c", "+x", " d", "+y"}, false},
+		{"missing trailing newline", "a\nb\n", "a\nb", []string{" a", " b"}, true},
+		{"changed without trailing newline", "a\nb", "a\nc", []string{" a", "-b", "+c"}, false},
+	}
+	for _, test := range tests {
+		d := diffText("from", "to", test.from, test.to)
+		if edits := showEdits(d.edits); !reflect.DeepEqual(edits, test.edits) {
+			t.Errorf("%s: got edits %q, want %q", test.name, edits, test.edits)
+		}
+		if d.newlineOnly != test.newlineOnly {
+			t.Errorf("%s: got newlineOnly %v, want %v", test.name, d.newlineOnly, test.newlineOnly)
+		}
+	}
+}
+
+func TestHunkHeaders(t *testing.T) {
+	numbered := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n17\n18\n19\n20\n"
+	tests := []struct {
+		name     string
+		from, to string
+		headers  []string
+	}{
+		{"added to empty", "", "a\nb\n", []string{"@@ -0,0 +1,2 @@"}},
+		{"removed to empty", "a\nb\n", "", []string{"@@ -1,2 +0,0 @@"}},
+		{"first line removed", "a\nb\n", "b\n", []string{"@@ -1,2 +1,1 @@"}},
+		{"last line added", "a\nb\n", "a\nb\nc\n", []string{"@@ -1,2 +1,3 @@"}},
+		{"middle line changed", numbered, strings.Replace(numbered, "\n10\n", "\nten\n", 1),
+			[]string{"@@ -7,7 +7,7 @@"}},
+		{"two far apart changes", numbered,
+			strings.Replace(strings.Replace(numbered, "\n3\n", "\n", 1), "\n17\n", "\n17\nextra\n", 1),
+			[]string{"@@ -1,6 +1,5 @@", "@@ -15,6 +14,7 @@"}},
+		{"two close changes", numbered,
+			strings.Replace(strings.Replace(numbered, "\n5\n", "\nfive\n", 1), "\n10\n", "\nten\n", 1),
+			[]string{"@@ -2,12 +2,12 @@"}},
+	}
+	for _, test := range tests {
+		headers := []string{}
+		for _, h := range diffText("from", "to", test.from, test.to).hunks(diffContext) {
+			headers = append(headers, h.header())
+		}
+		if !reflect.DeepEqual(headers, test.headers) {
+			t.Errorf("%s: got headers %q, want %q", test.name, headers, test.headers)
+		}
+	}
+}
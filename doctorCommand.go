@@ -0,0 +1,260 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// the oldest java the tools run on
+const minJavaVersion = 8
+
+// how long the emulator gets to run a program that only halts,
+// wine can take a while to start the first time it's run
+const doctorEmulatorTimeout = 30 * time.Second
+
+// doctorCommand checks everything gtr test assumes of the machine and the
+// directory it's run in, as a missing tool otherwise shows up as every test
+// failing with empty results
+func doctorCommand() {
+	color.Cyan("CHECKING...")
+	failures := 0
+	check := func(name, problem string) {
+		if problem == "" {
+			color.New(color.FgGreen).Printf("%-8s", "ok")
+			fmt.Println(name)
+			return
+		}
+		failures++
+		color.New(color.FgRed).Printf("%-8s", "failed")
+		fmt.Println(name + ": " + problem)
+	}
+
+	version, javaMajor, problem := checkJava()
+	check(strings.TrimSpace(java+" "+version), problem)
+	for _, jar := range []string{compilerName, codegeneratorName, optimizerName} {
+		path := buildPath(binDir, jar)
+		check(path, checkJar(path, javaMajor))
+	}
+	emulator := buildPath(binDir, emulatorName)
+	check(emulator, checkEmulator(emulator))
+	check(wine, checkWine(emulator))
+
+	missing := []string{}
+	for _, dir := range requiredDirs() {
+		if !exists(dir) {
+			missing = append(missing, dir)
+		}
+	}
+	if len(missing) == 0 {
+		check("directories", "")
+	} else {
+		check("directories", "missing "+strings.Join(missing, ", ")+", run gtr init")
+	}
+
+	// diffs are built in, so git is only needed to bisect, and its absence
+	// is no reason for gtr test to fail
+	if _, err := exec.LookPath("git"); err != nil {
+		color.New(color.FgYellow).Printf("%-8s", "warning")
+		fmt.Println("git: not found on PATH, only bisect-git needs it, diffs are built in")
+	} else {
+		check("git, for bisect-git", "")
+	}
+
+	if failures > 0 {
+		color.Magenta(fmt.Sprint(failures, " checks failed, gtr test won't give meaningful results"))
+		os.Exit(1)
+	}
+}
+
+var javaVersionPattern = regexp.MustCompile(`version "([^"]+)"`)
+
+// checkJava finds the version of the java on PATH, and whether it's recent
+// enough to run the tools, along with its major version, which is 0 if it
+// can't be found
+func checkJava() (string, int, string) {
+	if _, err := exec.LookPath(java); err != nil {
+		return "", 0, "not found on PATH"
+	}
+	output, err := exec.Command(java, "-version").CombinedOutput()
+	if err != nil {
+		return "", 0, "java -version failed: " + firstLine(string(output))
+	}
+	match := javaVersionPattern.FindStringSubmatch(string(output))
+	if match == nil {
+		return "", 0, "couldn't find a version in: " + firstLine(string(output))
+	}
+	version := match[1]
+
+	// versions before 9 are numbered 1.x
+	parts := strings.FieldsFunc(version, func(r rune) bool {
+		return r == '.' || r == '_' || r == '-' || r == '+'
+	})
+	if len(parts) > 1 && parts[0] == "1" {
+		parts = parts[1:]
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return version, 0, "couldn't make sense of the version"
+	}
+	if major < minJavaVersion {
+		return version, major, fmt.Sprint("the tools need java ", minJavaVersion, " or later")
+	}
+	return version, major, ""
+}
+
+// class file versions are java versions offset by this much, java 8 writes 52
+const classVersionOffset = 44
+
+// checkJar checks that the jar at path is one the java on PATH, of version
+// javaMajor, can run: a jar whose manifest names the class to run, which was
+// compiled for that java or an older one
+func checkJar(path string, javaMajor int) string {
+	if !exists(path) {
+		return "does not exist"
+	}
+	manifest, err := jarManifest(path)
+	if err != nil {
+		return "isn't a jar: " + err.Error()
+	}
+	if manifest == nil {
+		return "has no manifest, so java -jar can't run it"
+	}
+	mainClass := manifest["Main-Class"]
+	if mainClass == "" {
+		return "its manifest names no Main-Class, so java -jar can't run it"
+	}
+
+	classVersion, err := jarClassVersion(path, mainClass)
+	if err != nil {
+		return "can't load its Main-Class " + mainClass + ": " + err.Error()
+	}
+	if built := classVersion - classVersionOffset; javaMajor > 0 && built > javaMajor {
+		return fmt.Sprint("it was built for java ", built, ", but the java on PATH is ", javaMajor)
+	}
+	return ""
+}
+
+// jarClassVersion reads the major version of the class file for class, in
+// the jar at path
+func jarClassVersion(path, class string) (int, error) {
+	jar, err := zip.OpenReader(path)
+	if err != nil {
+		return 0, err
+	}
+	defer jar.Close()
+	name := strings.Replace(class, ".", "/", -1) + ".class"
+	for _, file := range jar.File {
+		if file.Name != name {
+			continue
+		}
+		contents, err := file.Open()
+		if err != nil {
+			return 0, err
+		}
+		defer contents.Close()
+
+		// magic number, minor version, then major version, all big endian
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(contents, header); err != nil {
+			return 0, err
+		}
+		if binary.BigEndian.Uint32(header) != 0xCAFEBABE {
+			return 0, errors.New(name + " isn't a class file")
+		}
+		return int(binary.BigEndian.Uint16(header[6:])), nil
+	}
+	return 0, errors.New(name + " isn't in the jar")
+}
+
+// jarManifest reads the main attributes of the manifest of the jar at path,
+// it is nil if the jar has no manifest
+func jarManifest(path string) (map[string]string, error) {
+	jar, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer jar.Close()
+	for _, file := range jar.File {
+		if file.Name != "META-INF/MANIFEST.MF" {
+			continue
+		}
+		contents, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer contents.Close()
+
+		// the main attributes end at the first blank line
+		manifest := map[string]string{}
+		scanner := bufio.NewScanner(contents)
+		for scanner.Scan() && strings.TrimSpace(scanner.Text()) != "" {
+			parts := strings.SplitN(scanner.Text(), ":", 2)
+			if len(parts) == 2 {
+				manifest[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+			}
+		}
+		return manifest, scanner.Err()
+	}
+	return nil, nil
+}
+
+// checkEmulator checks that the emulator at path is a windows executable
+func checkEmulator(path string) string {
+	if !exists(path) {
+		return "does not exist"
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err.Error()
+	}
+	defer file.Close()
+	header := make([]byte, 2)
+	if _, err := file.Read(header); err != nil || string(header) != "MZ" {
+		return "isn't a windows executable"
+	}
+	return ""
+}
+
+// checkWine checks that wine is installed and can run the emulator at
+// path, by having it run a program which only halts
+func checkWine(emulator string) string {
+	if _, err := exec.LookPath(wine); err != nil {
+		return "not found on PATH"
+	}
+	if !exists(emulator) {
+		return "can't check it runs the emulator, as there is no emulator"
+	}
+
+	dir, err := ioutil.TempDir("", tempPrefix)
+	crashOnError(err)
+	defer os.RemoveAll(dir)
+	program := buildPath(dir, "halt"+asmExt)
+	crashOnError(ioutil.WriteFile(program, []byte(basicAsmFile), 0777))
+
+	executeTimeout = doctorEmulatorTimeout
+	output, exitCode := runEmulator(program)
+	if timedOut(string(output)) {
+		return fmt.Sprint("the emulator took more than ", doctorEmulatorTimeout, " to run a program which only halts")
+	}
+	if exitCode != 0 {
+		return fmt.Sprint("the emulator exited with code ", exitCode, ": ", firstLine(string(output)))
+	}
+	return ""
+}
+
+func firstLine(s string) string {
+	return strings.SplitN(strings.TrimSpace(s), "\n", 2)[0]
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"os"
 	"runtime"
+	"time"
 
 	"github.com/fatih/color"
 )
@@ -24,6 +25,12 @@ type testFlags struct {
 	invertFlags bool
 
 	threads int
+
+	profile string
+
+	limits resourceLimits
+
+	pinned bool
 }
 
 type viewFlags struct {
@@ -36,7 +43,30 @@ type viewFlags struct {
 	asmo   bool
 	buildo bool
 
-	diff bool
+	diff      bool
+	diffStyle string
+
+	failed bool
+
+	optimizations bool
+
+	// testSets are the sets viewed when viewing more than one test, just
+	// testSet if it was given, every set otherwise
+	testSets []string
+}
+
+// phases are the phases picked out by flags, none means every phase
+func (flags viewFlags) phases() []string {
+	selected := []string{}
+	for phase, picked := range map[string]bool{
+		build: flags.build, asm: flags.asm, run: flags.run,
+		buildo: flags.buildo, asmo: flags.asmo,
+	} {
+		if picked {
+			selected = append(selected, phase)
+		}
+	}
+	return selected
 }
 
 type createFlags struct {
@@ -49,6 +79,151 @@ type acceptFlags struct {
 	asm bool
 
 	all bool
+
+	testSet string
+	phase   string
+
+	dryRun bool
+	force  bool
+
+	reason string
+
+	history bool
+}
+
+type mvFlags struct {
+	asm bool
+}
+
+type rmFlags struct {
+	asm bool
+}
+
+type pruneFlags struct {
+	dryRun bool
+}
+
+type metricsFlags struct {
+	testSet string
+
+	save    bool
+	verbose bool
+}
+
+type benchFlags struct {
+	testSet string
+
+	runs      int
+	threshold float64
+	timeout   time.Duration
+	limits    resourceLimits
+
+	save bool
+}
+
+type compareToolchainsFlags struct {
+	a string
+	b string
+
+	asm     bool
+	testSet string
+
+	diffStyle string
+
+	timeout time.Duration
+	limits  resourceLimits
+	threads int
+}
+
+// testSets are the sets whose pipelines are compared
+func (flags compareToolchainsFlags) testSets() []string {
+	if flags.asm {
+		return []string{optimizerStandalone}
+	}
+	if flags.testSet == "all" {
+		return []string{codegenerator, optimizer, compiler}
+	}
+	return []string{flags.testSet}
+}
+
+type bisectFlags struct {
+	asm bool
+
+	testSet string
+	phase   string
+
+	jars string
+
+	timeout time.Duration
+	limits  resourceLimits
+}
+
+type bisectGitFlags struct {
+	bisectFlags
+
+	repo  string
+	build string
+	jar   string
+
+	good string
+	bad  string
+
+	verbose bool
+}
+
+type toolchainFlags struct {
+	version string
+}
+
+type blameFlags struct {
+	asm bool
+}
+
+type undoFlags struct {
+	force bool
+}
+
+type reviewFlags struct {
+	testSet string
+
+	all bool
+}
+
+type reduceFlags struct {
+	asm bool
+
+	testSet string
+	phase   string
+
+	name string
+	open bool
+
+	timeout time.Duration
+	limits  resourceLimits
+}
+
+type fuzzFlags struct {
+	asm bool
+
+	testSet string
+
+	count int
+	seed  int64
+
+	timeout time.Duration
+	limits  resourceLimits
+	threads int
+}
+
+// testSets are the sets whose pipelines the fuzzer checks against its oracle
+func (flags fuzzFlags) testSets() []string {
+	if flags.asm {
+		return []string{optimizerStandalone}
+	}
+	if flags.testSet == "all" {
+		return []string{codegenerator, optimizer, compiler}
+	}
+	return []string{flags.testSet}
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -80,15 +255,29 @@ func makeTestFlags(args []string) testFlags {
 	test.IntVar(&flags.threads, "threads", runtime.NumCPU()+1,
 		"Set the maximum number of threads allowed for running tests\n"+
 			"\tdefaults to the number of CPUs + 1")
+
+	test.StringVar(&flags.profile, "profile", "",
+		"Time every tool run and comparison, reporting the slowest tests in each\n"+
+			"\tstep, and write a Chrome trace of the run to the file given")
+
+	addLimitFlags(test, &flags.limits)
+
+	test.BoolVar(&flags.pinned, "pinned", false,
+		"refuse to run unless every tool in bin matches its pin in "+toolchainManifestFile+"\n"+
+			"\tand is pinned there, any which don't are otherwise just warned about")
 	test.Parse(args)
 	return flags
 }
 
-func makeViewFlags(args []string) (viewFlags, string) {
+func makeViewFlags(args []string) (viewFlags, []string) {
 	flags := viewFlags{}
 	view := flag.NewFlagSet("view", flag.ExitOnError)
 	view.BoolVar(&flags.diff, "diff", false,
 		"view as a diff, instead of result and expectation separately")
+	view.StringVar(&flags.diffStyle, "diff-style", unifiedDiff,
+		"how to lay out the diff\n"+
+			"\tvalues:\n"+
+			"\tunified, side-by-side, words")
 
 	view.BoolVar(&flags.test, "test", false,
 		"view the source of the test which was run")
@@ -106,26 +295,47 @@ func makeViewFlags(args []string) (viewFlags, string) {
 		"compare results of the build phase of testing")
 	view.BoolVar(&flags.asmo, "asmo", false,
 		"compare the asm generated by the reoptimizing phase of testing")
-	view.BoolVar(&flags.build, "buildo", false,
+	view.BoolVar(&flags.buildo, "buildo", false,
 		"compare results of the reoptimizing phase of testing")
 
-	view.Parse(args)
-	switch flags.testSet {
-	case "compiler",
-		"codegenerator",
-		"optimizer",
-		"optimizer-standalone":
-		// do nothing
-	default:
-		color.Magenta("-test-set=" + flags.testSet + " is invalid")
-		os.Exit(1)
-	}
+	view.BoolVar(&flags.failed, "failed", false,
+		"view the diff of every result which failed in the last run\n"+
+			"\tnarrowed down by any test names or globs given as <target>s\n"+
+			"\tviewing more than one test always shows diffs, through $PAGER")
+
+	view.BoolVar(&flags.optimizations, "optimizations", false,
+		"view what the optimizer did to a test, diffing the asm it was given\n"+
+			"\tagainst the asm it wrote, along with instruction and cycle counts\n"+
+			"\tdefaults to -test-set=optimizer, compiler needs a -reoptimize run")
 
-	if len(view.Args()) == 0 {
+	targets := parseArgs(view, args)
+	validateTestSet("-test-set", flags.testSet)
+	validateDiffStyle("-diff-style", flags.diffStyle)
+
+	flags.testSets = testSets
+	view.Visit(func(f *flag.Flag) {
+		if f.Name == "test-set" {
+			flags.testSets = []string{flags.testSet}
+		}
+	})
+
+	if len(targets) == 0 && !flags.failed {
 		color.Magenta("No test was specified to view")
 		os.Exit(1)
 	}
-	return flags, view.Arg(0)
+	if flags.optimizations {
+		if flags.failed || len(targets) != 1 || isGlob(targets[0]) {
+			color.Magenta("-optimizations views a single test, give just its name")
+			os.Exit(1)
+		}
+		if len(flags.testSets) > 1 {
+			flags.testSet = optimizer
+		} else if flags.testSet == codegenerator {
+			color.Magenta("the codegenerator's output is never optimized")
+			os.Exit(1)
+		}
+	}
+	return flags, targets
 }
 
 func makeCreateFlags(args []string) (createFlags, string) {
@@ -144,7 +354,7 @@ func makeCreateFlags(args []string) (createFlags, string) {
 	return flags, create.Arg(0)
 }
 
-func makeAcceptFlags(args []string) (acceptFlags, string) {
+func makeAcceptFlags(args []string) (acceptFlags, []string) {
 	flags := acceptFlags{}
 	accept := flag.NewFlagSet("accept", flag.ExitOnError)
 
@@ -152,18 +362,500 @@ func makeAcceptFlags(args []string) (acceptFlags, string) {
 		"test specified is a .asm file, default to .pika otherwise")
 
 	accept.BoolVar(&flags.all, "all", false,
-		"move result folder to expect\n"+
-			"\tsupersedes all other args\n"+
-			"\tsaves old expects to .backup folder\n"+
-			"\tanything previously in .backup gets deleted")
+		"accept the results of every test, in every set including optimizer-standalone\n"+
+			"\tstill only those of -set and -phase when given, any tests named are ignored")
+
+	accept.StringVar(&flags.testSet, "set", "",
+		"only accept results from this set of tests, defaults to every set\n"+
+			"\tvalues:\n"+
+			"\tcodegenerator, compiler, optimizer, optimizer-standalone")
+	accept.StringVar(&flags.phase, "phase", "",
+		"only accept results from this phase of testing, defaults to every phase\n"+
+			"\tvalues:\n"+
+			"\tbuild, asm, run, buildo, asmo")
+
+	accept.BoolVar(&flags.dryRun, "dry-run", false,
+		"show which expectations would change, and how, without changing them")
+
+	accept.BoolVar(&flags.force, "force", false,
+		"accept results even if they look broken: java stack traces, timeouts,\n"+
+			"\tempty runs of non-empty expectations, or tools exiting with an error")
 
-	accept.Parse(args)
-	if len(accept.Args()) == 0 && flags.all == false {
+	accept.StringVar(&flags.reason, "m", "",
+		"reason for accepting, kept with the provenance of each expectation")
+
+	accept.BoolVar(&flags.history, "history", false,
+		"list every accept made, newest first\n"+
+			"\tany of them can be rolled back with gtr undo <id>")
+
+	targets := parseArgs(accept, args)
+	if flags.history {
+		return flags, nil
+	}
+	if flags.testSet != "" {
+		validateTestSet("-set", flags.testSet)
+	}
+	if flags.phase != "" {
+		validatePhase("-phase", flags.phase)
+	}
+	if len(targets) == 0 && flags.all == false {
 		color.Magenta("No test was specified to accept")
 		os.Exit(1)
 	}
-	if len(accept.Args()) == 0 {
+	return flags, targets
+}
+
+func makeMvFlags(args []string) (mvFlags, string, string) {
+	flags := mvFlags{}
+	mv := flag.NewFlagSet("mv", flag.ExitOnError)
+	mv.BoolVar(&flags.asm, "asm", false,
+		"rename an asm test for the optimizer-standalone set, default to pika otherwise")
+
+	targets := parseArgs(mv, args)
+	if len(targets) != 2 {
+		color.Magenta("mv requires the test's current name and its new name")
+		os.Exit(1)
+	}
+	return flags, targets[0], targets[1]
+}
+
+func makeRmFlags(args []string) (rmFlags, string) {
+	flags := rmFlags{}
+	rm := flag.NewFlagSet("rm", flag.ExitOnError)
+	rm.BoolVar(&flags.asm, "asm", false,
+		"delete an asm test for the optimizer-standalone set, default to pika otherwise")
+
+	targets := parseArgs(rm, args)
+	if len(targets) == 0 {
+		color.Magenta("No test was specified to delete")
+		os.Exit(1)
+	}
+	return flags, targets[0]
+}
+
+func makePruneFlags(args []string) pruneFlags {
+	flags := pruneFlags{}
+	prune := flag.NewFlagSet("prune", flag.ExitOnError)
+	prune.BoolVar(&flags.dryRun, "dry-run", false,
+		"list what would be deleted without deleting anything")
+
+	parseArgs(prune, args)
+	return flags
+}
+
+func makeMetricsFlags(args []string) metricsFlags {
+	flags := metricsFlags{}
+	metrics := flag.NewFlagSet("metrics", flag.ExitOnError)
+	metrics.StringVar(&flags.testSet, "set", "",
+		"only report on one set of tests, defaults to every set measured\n"+
+			"\tvalues:\n"+
+			"\tcompiler, optimizer, optimizer-standalone")
+	metrics.BoolVar(&flags.save, "save", false,
+		"save the metrics of the last run as the baseline, instead of reporting")
+	metrics.BoolVar(&flags.verbose, "v", false,
+		"list the outputs which got better, as well as those which got worse")
+
+	parseArgs(metrics, args)
+	switch flags.testSet {
+	case "", compiler, optimizer, optimizerStandalone:
+		// do nothing
+	default:
+		color.Magenta("-set=" + flags.testSet + " is invalid")
+		os.Exit(1)
+	}
+	return flags
+}
+
+func makeBenchFlags(args []string) (benchFlags, []string) {
+	flags := benchFlags{}
+	bench := flag.NewFlagSet("bench", flag.ExitOnError)
+	bench.StringVar(&flags.testSet, "set", compiler,
+		"set of tests whose run phase is timed, they must have been built already\n"+
+			"\tvalues:\n"+
+			"\tcodegenerator, compiler, optimizer, optimizer-standalone")
+
+	bench.IntVar(&flags.runs, "n", 5,
+		"number of timed runs of each test, after one untimed warm up run")
+	bench.Float64Var(&flags.threshold, "threshold", 0.05,
+		"smallest slowdown worth flagging, as a fraction of the baseline's median")
+	bench.DurationVar(&flags.timeout, "timeout", time.Minute,
+		"give up on any test whose run takes longer than this")
+	addLimitFlags(bench, &flags.limits)
+
+	bench.BoolVar(&flags.save, "save", false,
+		"save the timings as the baseline to compare later runs against")
+
+	targets := parseArgs(bench, args)
+	validateTestSet("-set", flags.testSet)
+	if flags.runs < 2 {
+		color.Magenta("-n must be at least 2 to measure any variance")
+		os.Exit(1)
+	}
+	return flags, targets
+}
+
+func makeCompareToolchainsFlags(args []string) (compareToolchainsFlags, []string) {
+	flags := compareToolchainsFlags{}
+	compare := flag.NewFlagSet("compare-toolchains", flag.ExitOnError)
+	compare.StringVar(&flags.a, "a", binDir,
+		"directory of the first toolchain's jars, and optionally its emulator")
+	compare.StringVar(&flags.b, "b", "",
+		"directory of the second toolchain's jars, and optionally its emulator")
+
+	compare.BoolVar(&flags.asm, "asm", false,
+		"compare the optimizer-standalone set, default to pika otherwise")
+	compare.StringVar(&flags.testSet, "set", "all",
+		"set of tests whose pipeline is compared\n"+
+			"\tvalues:\n"+
+			"\tcodegenerator, compiler, optimizer, all")
+	compare.StringVar(&flags.diffStyle, "diff-style", unifiedDiff,
+		"how to lay out the diffs\n"+
+			"\tvalues:\n"+
+			"\tunified, side-by-side, words")
+
+	compare.DurationVar(&flags.timeout, "timeout", time.Minute,
+		"kill any tool or emulator run that takes longer than this")
+	addLimitFlags(compare, &flags.limits)
+	compare.IntVar(&flags.threads, "threads", runtime.NumCPU()+1,
+		"Set the maximum number of threads allowed for running tests\n"+
+			"\tdefaults to the number of CPUs + 1")
+
+	targets := parseArgs(compare, args)
+	switch flags.testSet {
+	case codegenerator, compiler, optimizer, "all":
+		// do nothing
+	default:
+		color.Magenta("-set=" + flags.testSet + " is invalid")
+		os.Exit(1)
+	}
+	validateDiffStyle("-diff-style", flags.diffStyle)
+	if flags.b == "" {
+		color.Magenta("-b is required, the toolchain to compare against " + flags.a)
+		os.Exit(1)
+	}
+	return flags, targets
+}
+
+func makeBisectFlags(args []string) (bisectFlags, string) {
+	flags := bisectFlags{}
+	bisect := flag.NewFlagSet("bisect", flag.ExitOnError)
+	addBisectFlags(bisect, &flags)
+	bisect.StringVar(&flags.jars, "jars", "",
+		"directory of snapshots, oldest first when sorted by name\n"+
+			"\teach is either a directory of jars standing in for bin,\n"+
+			"\tor a single jar standing in for the jar of the set's tool")
+
+	targets := parseArgs(bisect, args)
+	validateBisectFlags(&flags)
+	if flags.jars == "" {
+		color.Magenta("-jars is required, the directory of snapshots to bisect")
+		os.Exit(1)
+	}
+	if len(targets) == 0 {
+		color.Magenta("No test was specified to bisect")
+		os.Exit(1)
+	}
+	return flags, targets[0]
+}
+
+func makeBisectGitFlags(args []string) (bisectGitFlags, string) {
+	flags := bisectGitFlags{}
+	bisect := flag.NewFlagSet("bisect-git", flag.ExitOnError)
+	addBisectFlags(bisect, &flags.bisectFlags)
+	bisect.StringVar(&flags.repo, "repo", "",
+		"the git repository the tool is built from")
+	bisect.StringVar(&flags.build, "build", "",
+		"shell command which builds the tool, run in -repo")
+	bisect.StringVar(&flags.jar, "jar", "",
+		"where in -repo the build leaves the jar, which is copied into bin\n"+
+			"\tdefaults to the name of the set's tool, at the top of -repo")
+
+	bisect.StringVar(&flags.good, "good", "",
+		"a commit in which the test passes")
+	bisect.StringVar(&flags.bad, "bad", "HEAD",
+		"a commit in which the test fails")
+	bisect.BoolVar(&flags.verbose, "v", false,
+		"show the output of builds which fail")
+
+	targets := parseArgs(bisect, args)
+	validateBisectFlags(&flags.bisectFlags)
+	for _, required := range []struct{ name, value string }{
+		{"-repo", flags.repo}, {"-build", flags.build}, {"-good", flags.good},
+	} {
+		if required.value == "" {
+			color.Magenta(required.name + " is required")
+			os.Exit(1)
+		}
+	}
+	if len(targets) == 0 {
+		color.Magenta("No test was specified to bisect")
+		os.Exit(1)
+	}
+	return flags, targets[0]
+}
+
+// addBisectFlags adds the flags every kind of bisect shares, for picking out
+// the output which is bisected
+func addBisectFlags(bisect *flag.FlagSet, flags *bisectFlags) {
+	bisect.BoolVar(&flags.asm, "asm", false,
+		"test specified is a .asm file for the optimizer-standalone set, default to .pika otherwise")
+	bisect.StringVar(&flags.testSet, "set", compiler,
+		"set of tests whose pipeline is bisected\n"+
+			"\tvalues:\n"+
+			"\tcodegenerator, compiler, optimizer")
+	bisect.StringVar(&flags.phase, "phase", run,
+		"phase whose output is checked against its expectation\n"+
+			"\tvalues:\n"+
+			"\tbuild, asm, run")
+
+	bisect.DurationVar(&flags.timeout, "timeout", time.Minute,
+		"kill any tool or emulator run that takes longer than this\n"+
+			"\ta snapshot which times out counts as bad")
+	addLimitFlags(bisect, &flags.limits)
+}
+
+func validateBisectFlags(flags *bisectFlags) {
+	validateTestSet("-set", flags.testSet)
+	if flags.asm {
+		flags.testSet = optimizerStandalone
+	} else if flags.testSet == optimizerStandalone {
+		color.Magenta("-set=" + flags.testSet + " is invalid for pika tests, use -asm")
+		os.Exit(1)
+	}
+	switch flags.phase {
+	case build, asm, run:
+		// do nothing
+	default:
+		color.Magenta("-phase=" + flags.phase + " is invalid")
+		os.Exit(1)
+	}
+}
+
+func makeToolchainFlags(args []string) (toolchainFlags, string, []string) {
+	flags := toolchainFlags{}
+	toolchain := flag.NewFlagSet("toolchain", flag.ExitOnError)
+	toolchain.StringVar(&flags.version, "version", "",
+		"version label to pin the tools at\n"+
+			"\tdefaults to the Implementation-Version in each jar's manifest")
+
+	targets := parseArgs(toolchain, args)
+	if len(targets) == 0 {
+		targets = []string{"status"}
+	}
+	switch targets[0] {
+	case "pin", "status":
+		// do nothing
+	default:
+		color.Magenta("invalid toolchain command: \"" + targets[0] + "\", use pin or status")
+		os.Exit(1)
+	}
+	for _, tool := range targets[1:] {
+		if !containsString(pinnedTools, tool) {
+			color.Magenta(tool + " isn't a tool which can be pinned")
+			os.Exit(1)
+		}
+	}
+	return flags, targets[0], targets[1:]
+}
+
+func makeBlameFlags(args []string) (blameFlags, string) {
+	flags := blameFlags{}
+	blame := flag.NewFlagSet("blame", flag.ExitOnError)
+	blame.BoolVar(&flags.asm, "asm", false,
+		"test specified is a .asm file, default to .pika otherwise")
+
+	targets := parseArgs(blame, args)
+	if len(targets) == 0 {
+		color.Magenta("No test was specified to blame")
+		os.Exit(1)
+	}
+	return flags, targets[0]
+}
+
+func makeUndoFlags(args []string) (undoFlags, string) {
+	flags := undoFlags{}
+	undo := flag.NewFlagSet("undo", flag.ExitOnError)
+	undo.BoolVar(&flags.force, "force", false,
+		"undo even if later accepts changed the same expectations,\n"+
+			"\tthrowing those changes away")
+
+	targets := parseArgs(undo, args)
+	if len(targets) == 0 {
 		return flags, ""
 	}
-	return flags, accept.Arg(0)
+	return flags, targets[0]
+}
+
+func makeReviewFlags(args []string) reviewFlags {
+	flags := reviewFlags{}
+	review := flag.NewFlagSet("review", flag.ExitOnError)
+	review.StringVar(&flags.testSet, "set", "",
+		"only review this set of tests, defaults to every set\n"+
+			"\tvalues:\n"+
+			"\tcodegenerator, compiler, optimizer, optimizer-standalone")
+	review.BoolVar(&flags.all, "all", false,
+		"also review results marked as known failures")
+
+	parseArgs(review, args)
+	if flags.testSet != "" {
+		validateTestSet("-set", flags.testSet)
+	}
+	return flags
+}
+
+func makeReduceFlags(args []string) (reduceFlags, string) {
+	flags := reduceFlags{}
+	reduce := flag.NewFlagSet("reduce", flag.ExitOnError)
+	reduce.BoolVar(&flags.asm, "asm", false,
+		"test specified is a .asm file, default to .pika otherwise\n"+
+			"\treduces it through the optimizer-standalone pipeline")
+	reduce.StringVar(&flags.testSet, "set", compiler,
+		"set of tests whose pipeline the failure shows up in\n"+
+			"\tvalues:\n"+
+			"\tcodegenerator, compiler, optimizer")
+	reduce.StringVar(&flags.phase, "phase", run,
+		"phase of testing the failure shows up in\n"+
+			"\tvalues:\n"+
+			"\tbuild, run")
+
+	reduce.StringVar(&flags.name, "name", "",
+		"name of the test to write the reproducer to\n"+
+			"\tdefaults to <target>-reduced")
+	reduce.BoolVar(&flags.open, "open", false,
+		"opens the reproducer in your default editor once it is written")
+
+	reduce.DurationVar(&flags.timeout, "timeout", 10*time.Second,
+		"kill any tool or emulator run that takes longer than this\n"+
+			"\tprograms which hang keep their timeout as a failure")
+	addLimitFlags(reduce, &flags.limits)
+
+	targets := parseArgs(reduce, args)
+	validateTestSet("-set", flags.testSet)
+	if flags.asm {
+		flags.testSet = optimizerStandalone
+	} else if flags.testSet == optimizerStandalone {
+		color.Magenta("-set=" + flags.testSet + " is invalid for pika tests, use -asm")
+		os.Exit(1)
+	}
+	switch flags.phase {
+	case build, run:
+		// do nothing
+	default:
+		color.Magenta("-phase=" + flags.phase + " is invalid")
+		os.Exit(1)
+	}
+
+	if len(targets) == 0 {
+		color.Magenta("No test was specified to reduce")
+		os.Exit(1)
+	}
+	return flags, targets[0]
+}
+
+func makeFuzzFlags(args []string) fuzzFlags {
+	flags := fuzzFlags{}
+	fuzz := flag.NewFlagSet("fuzz", flag.ExitOnError)
+	fuzz.BoolVar(&flags.asm, "asm", false,
+		"generate asm for the optimizer-standalone set, default to pika otherwise\n"+
+			"\tthe optimized run is checked against the unoptimized run")
+	fuzz.StringVar(&flags.testSet, "set", "all",
+		"set of tests whose pipeline is checked against the codegenerator\n"+
+			"\tvalues:\n"+
+			"\tcodegenerator, compiler, optimizer, all")
+
+	fuzz.IntVar(&flags.count, "n", 100,
+		"number of programs to generate")
+	fuzz.Int64Var(&flags.seed, "seed", time.Now().UnixNano(),
+		"seed of the first program, each following program uses the next seed\n"+
+			"\tdefaults to the current time")
+
+	fuzz.DurationVar(&flags.timeout, "timeout", 10*time.Second,
+		"kill any tool or emulator run that takes longer than this")
+	addLimitFlags(fuzz, &flags.limits)
+	fuzz.IntVar(&flags.threads, "threads", runtime.NumCPU()+1,
+		"Set the maximum number of threads allowed for running tests\n"+
+			"\tdefaults to the number of CPUs + 1")
+
+	parseArgs(fuzz, args)
+	switch flags.testSet {
+	case codegenerator, compiler, optimizer, "all":
+		// do nothing
+	default:
+		color.Magenta("-set=" + flags.testSet + " is invalid")
+		os.Exit(1)
+	}
+	if flags.count <= 0 {
+		color.Magenta("-n must be positive")
+		os.Exit(1)
+	}
+	return flags
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// helpers
+
+// parseArgs parses flags which may come before, after or in between the
+// positional arguments, returning just the positional arguments
+func parseArgs(set *flag.FlagSet, args []string) []string {
+	positional := []string{}
+	for {
+		set.Parse(args)
+		args = set.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// addLimitFlags adds the flags for the resource limits every tool and
+// emulator run is held to
+func addLimitFlags(set *flag.FlagSet, limits *resourceLimits) {
+	set.IntVar(&limits.memoryMB, "max-memory", 0,
+		"kill any tool or emulator run whose address space grows past this many MB\n"+
+			"\tthe jvm and wine reserve far more than they use, so leave plenty of room\n"+
+			"\tdefaults to no limit")
+	set.DurationVar(&limits.cpu, "max-cpu", 0,
+		"kill any tool or emulator run that uses more cpu time than this\n"+
+			"\tdefaults to no limit")
+	set.IntVar(&limits.outputMB, "max-output", defaultMaxOutputMB,
+		"kill any tool or emulator run that prints more than this many MB\n"+
+			"\t0 means no limit")
+	set.IntVar(&limits.files, "max-files", 0,
+		"limit how many files any tool or emulator run may have open at once\n"+
+			"\tdefaults to no limit")
+}
+
+func validatePhase(name, phase string) {
+	for _, valid := range phases {
+		if phase == valid {
+			return
+		}
+	}
+	color.Magenta(name + "=" + phase + " is invalid")
+	os.Exit(1)
+}
+
+func validateDiffStyle(name, style string) {
+	switch style {
+	case unifiedDiff, sideBySideDiff, wordDiff:
+		// do nothing
+	default:
+		color.Magenta(name + "=" + style + " is invalid")
+		os.Exit(1)
+	}
+}
+
+func validateTestSet(name, testSet string) {
+	switch testSet {
+	case compiler,
+		codegenerator,
+		optimizer,
+		optimizerStandalone:
+		// do nothing
+	default:
+		color.Magenta(name + "=" + testSet + " is invalid")
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pikaVariable is what the generator knows about a variable in scope
+type pikaVariable struct {
+	typ     string
+	mutable bool
+}
+
+// pikaGenerator expands a grammar into a random program, tracking which
+// variables are in scope so that every program it makes is well typed
+type pikaGenerator struct {
+	random  *rand.Rand
+	grammar grammar
+
+	scopes []map[string]pikaVariable
+	names  int
+	indent int
+
+	depth    int
+	maxDepth int
+}
+
+var nonterminalPattern = regexp.MustCompile(`<([a-z-]+)>`)
+
+// generatePika makes a random pika program, the same seed always gives
+// back the same program
+func generatePika(seed int64) string {
+	generator := pikaGenerator{
+		random:   rand.New(rand.NewSource(seed)),
+		grammar:  pikaGrammar,
+		scopes:   []map[string]pikaVariable{{}},
+		indent:   1,
+		maxDepth: 6,
+	}
+	program, _ := generator.expand("program")
+	return program
+}
+
+func (g *pikaGenerator) expand(symbol string) (string, bool) {
+	switch symbol {
+	case "stmts":
+		return g.statements(5, 15), true
+	case "block":
+		g.scopes = append(g.scopes, map[string]pikaVariable{})
+		g.indent++
+		block := g.statements(1, 4)
+		g.indent--
+		g.scopes = g.scopes[:len(g.scopes)-1]
+		return block, true
+	case "indent":
+		return strings.Repeat("\t", g.indent), true
+	case "int-literal":
+		return strconv.Itoa(g.random.Intn(100)), true
+	case "nonzero-int-literal":
+		return strconv.Itoa(1 + g.random.Intn(9)), true
+	case "float-literal":
+		return fmt.Sprintf("%d.%d", g.random.Intn(100), g.random.Intn(10)), true
+	case "nonzero-float-literal":
+		return fmt.Sprintf("%d.%d", 1+g.random.Intn(9), g.random.Intn(10)), true
+	}
+
+	if strings.HasSuffix(symbol, "-var") {
+		mutable := strings.HasPrefix(symbol, "mutable-")
+		typ := strings.TrimSuffix(strings.TrimPrefix(symbol, "mutable-"), "-var")
+		if name := g.pickVariable(typ, mutable); name != "" {
+			return name, true
+		}
+		if mutable {
+			return "", false
+		}
+		return g.expandForm(g.productions(typ)[0].form)
+	}
+
+	productions := g.productions(symbol)
+	g.depth++
+	defer func() { g.depth-- }()
+	if g.depth > g.maxDepth {
+		return g.expandForm(productions[0].form)
+	}
+	for tries := 0; tries < 10; tries++ {
+		if text, ok := g.expandForm(g.pick(productions).form); ok {
+			return text, true
+		}
+	}
+	return g.expandForm(productions[0].form)
+}
+
+// expandForm expands every nonterminal in form from left to right, variables
+// the form declares only come into scope once the whole form is expanded
+func (g *pikaGenerator) expandForm(form string) (string, bool) {
+	var out strings.Builder
+	counter := ""
+	declared := map[string]pikaVariable{}
+
+	rest := form
+	for {
+		match := nonterminalPattern.FindStringSubmatchIndex(rest)
+		if match == nil {
+			out.WriteString(rest)
+			break
+		}
+		out.WriteString(rest[:match[0]])
+		symbol := rest[match[2]:match[3]]
+		rest = rest[match[1]:]
+
+		var text string
+		ok := true
+		switch {
+		case symbol == "counter":
+			if counter == "" {
+				counter = g.freshName()
+			}
+			text = counter
+		case strings.HasPrefix(symbol, "new-"):
+			parts := strings.SplitN(symbol, "-", 3)
+			text = g.freshName()
+			declared[text] = pikaVariable{typ: parts[2], mutable: parts[1] == "var"}
+		default:
+			text, ok = g.expand(symbol)
+		}
+		if !ok {
+			return "", false
+		}
+		out.WriteString(text)
+	}
+
+	scope := g.scopes[len(g.scopes)-1]
+	for name, variable := range declared {
+		scope[name] = variable
+	}
+	return out.String(), true
+}
+
+func (g *pikaGenerator) statements(min, max int) string {
+	count := min + g.random.Intn(max-min+1)
+	var out strings.Builder
+	for i := 0; i < count; i++ {
+		statement, _ := g.expand("stmt")
+		out.WriteString(statement)
+	}
+	return out.String()
+}
+
+func (g *pikaGenerator) productions(symbol string) []production {
+	productions, ok := g.grammar[symbol]
+	if !ok || len(productions) == 0 {
+		log.Fatal("fuzz grammar has no productions for <" + symbol + ">")
+	}
+	return productions
+}
+
+func (g *pikaGenerator) pick(productions []production) production {
+	total := 0
+	for _, production := range productions {
+		total += production.weight
+	}
+	choice := g.random.Intn(total)
+	for _, production := range productions {
+		choice -= production.weight
+		if choice < 0 {
+			return production
+		}
+	}
+	return productions[0]
+}
+
+// pickVariable chooses any variable in scope of type typ,
+// or returns the empty string if there are none
+func (g *pikaGenerator) pickVariable(typ string, mutable bool) string {
+	names := []string{}
+	for _, scope := range g.scopes {
+		for name, variable := range scope {
+			if variable.typ == typ && (variable.mutable || !mutable) {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return names[g.random.Intn(len(names))]
+}
+
+func (g *pikaGenerator) freshName() string {
+	g.names++
+	return "v" + strconv.Itoa(g.names)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// asmGenerator makes random ASMEmu programs which always halt: loops count
+// up to a small bound, and subroutines only call the ones made before them
+type asmGenerator struct {
+	random *rand.Rand
+
+	labels      int
+	data        []string
+	variables   []string
+	subroutines []string
+	callable    []string
+
+	depth    int
+	maxDepth int
+}
+
+const asmPrintFormat = "fuzz-format-integer"
+
+// generateAsm makes a random ASMEmu program, the same seed always gives
+// back the same program
+func generateAsm(seed int64) string {
+	generator := asmGenerator{
+		random:   rand.New(rand.NewSource(seed)),
+		maxDepth: 3,
+	}
+	generator.data = append(generator.data,
+		dLabelOp+" "+asmPrintFormat,
+		"DataC 37",  // %
+		"DataC 100", // d
+		"DataC 10",  // \n
+		"DataC 0")
+
+	for i := generator.random.Intn(3); i > 0; i-- {
+		generator.subroutine()
+	}
+	body := generator.statements(5, 20)
+
+	lines := append([]string{}, generator.data...)
+	lines = append(lines, body...)
+	lines = append(lines, strings.TrimSpace(basicAsmFile))
+	lines = append(lines, generator.subroutines...)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (g *asmGenerator) statements(min, max int) []string {
+	lines := []string{}
+	count := min + g.random.Intn(max-min+1)
+	for i := 0; i < count; i++ {
+		lines = append(lines, g.statement()...)
+	}
+	return lines
+}
+
+// statement is a run of instructions which leaves the stack as it found it
+func (g *asmGenerator) statement() []string {
+	g.depth++
+	defer func() { g.depth-- }()
+
+	choice := g.random.Intn(10)
+	if g.depth > g.maxDepth {
+		choice = 0
+	}
+	switch {
+	case choice < 4:
+		return append(g.expression(),
+			"PushD "+asmPrintFormat,
+			"Printf")
+	case choice < 6:
+		var variable string
+		if len(g.variables) > 0 && g.random.Intn(2) == 0 {
+			variable = g.variables[g.random.Intn(len(g.variables))]
+		} else {
+			variable = g.variable()
+		}
+		lines := []string{"PushD " + variable}
+		lines = append(lines, g.expression()...)
+		return append(lines, "StoreI")
+	case choice < 7 && len(g.callable) > 0:
+		return []string{"Call " + g.callable[g.random.Intn(len(g.callable))]}
+	case choice < 8:
+		return g.conditional()
+	case choice < 9:
+		return g.loop()
+	}
+	// a jump straight to the next instruction, a favourite of peephole optimizers
+	label := g.label("skip")
+	return []string{"Jump " + label, labelOp + " " + label}
+}
+
+// expression is a run of instructions which pushes exactly one integer
+func (g *asmGenerator) expression() []string {
+	g.depth++
+	defer func() { g.depth-- }()
+
+	choice := g.random.Intn(12)
+	if g.depth > g.maxDepth+2 {
+		choice = 0
+	}
+	switch choice {
+	case 0, 1:
+		return []string{"PushI " + strconv.Itoa(g.random.Intn(100))}
+	case 2:
+		if len(g.variables) > 0 {
+			variable := g.variables[g.random.Intn(len(g.variables))]
+			return []string{"PushD " + variable, "LoadI"}
+		}
+		return []string{"PushI " + strconv.Itoa(g.random.Intn(100))}
+	case 3, 4, 5:
+		operators := []string{"Add", "Subtract", "Multiply"}
+		lines := append(g.expression(), g.expression()...)
+		return append(lines, operators[g.random.Intn(len(operators))])
+	case 6:
+		return append(g.expression(),
+			"PushI "+strconv.Itoa(1+g.random.Intn(9)),
+			"Divide")
+	case 7:
+		return append(g.expression(), "Negate")
+	case 8:
+		return append(g.expression(), "Duplicate", "Add")
+	case 9:
+		lines := append(g.expression(), g.expression()...)
+		return append(lines, "Exchange", "Subtract")
+	case 10:
+		identities := [][]string{
+			{"PushI 0", "Add"},
+			{"PushI 1", "Multiply"},
+			{"Duplicate", "Pop"},
+			{"Negate", "Negate"},
+		}
+		return append(g.expression(), identities[g.random.Intn(len(identities))]...)
+	}
+	return append(g.expression(), "PushI 0", "Multiply")
+}
+
+func (g *asmGenerator) conditional() []string {
+	elseLabel := g.label("else")
+	endLabel := g.label("endif")
+	lines := append(g.expression(), "JumpFalse "+elseLabel)
+	lines = append(lines, g.statements(1, 3)...)
+	lines = append(lines, "Jump "+endLabel, labelOp+" "+elseLabel)
+	lines = append(lines, g.statements(0, 3)...)
+	return append(lines, labelOp+" "+endLabel)
+}
+
+// loop runs its body a small fixed number of times, keeping its counter in
+// memory so the body is free to use the stack however it likes
+func (g *asmGenerator) loop() []string {
+	counter := g.memory("counter")
+	start := g.label("loop")
+	end := g.label("loop-end")
+	bound := strconv.Itoa(1 + g.random.Intn(5))
+
+	lines := []string{
+		"PushD " + counter, "PushI 0", "StoreI",
+		labelOp + " " + start,
+		"PushD " + counter, "LoadI", "PushI " + bound, "Subtract",
+		"JumpFalse " + end,
+	}
+	lines = append(lines, g.statements(1, 4)...)
+	return append(lines,
+		"PushD "+counter, "PushD "+counter, "LoadI", "PushI 1", "Add", "StoreI",
+		"Jump "+start,
+		labelOp+" "+end)
+}
+
+// subroutine adds a new callable subroutine, which keeps its return address
+// in memory while its body runs
+func (g *asmGenerator) subroutine() {
+	name := g.label("sub")
+	returnAddress := g.memory("return")
+
+	g.depth++
+	body := g.statements(1, 4)
+	g.depth--
+
+	lines := []string{
+		labelOp + " " + name,
+		"PushD " + returnAddress, "Exchange", "StoreI",
+	}
+	lines = append(lines, body...)
+	lines = append(lines, "PushD "+returnAddress, "LoadI", "Return")
+
+	g.subroutines = append(g.subroutines, lines...)
+	g.callable = append(g.callable, name)
+}
+
+// variable declares a new word of memory which any statement may store to
+func (g *asmGenerator) variable() string {
+	name := g.memory("var")
+	g.variables = append(g.variables, name)
+	return name
+}
+
+// memory declares a new word of memory, initialized to zero
+func (g *asmGenerator) memory(kind string) string {
+	name := g.label(kind)
+	g.data = append(g.data, dLabelOp+" "+name, "DataI 0")
+	return name
+}
+
+func (g *asmGenerator) label(kind string) string {
+	g.labels++
+	return "fuzz-" + kind + "-" + strconv.Itoa(g.labels)
+}
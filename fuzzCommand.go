@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/fatih/color"
+)
+
+func fuzzCommand(flags fuzzFlags) {
+	executeTimeout = flags.timeout
+	executeLimits = flags.limits
+
+	workDir, err := ioutil.TempDir("", tempPrefix)
+	crashOnError(err)
+	defer os.RemoveAll(workDir)
+	srcDir := buildPath(workDir, "src")
+	mkdirIfNotExist(srcDir)
+	mkdirIfNotExist(buildPath(workDir, run, unoptimized))
+
+	generate, ext := generatePika, pikaExt
+	if flags.asm {
+		generate, ext = generateAsm, asmExt
+	}
+
+	color.Cyan("GENERATING...")
+	sources := make(map[string]string, flags.count)
+	names := make([]string, 0, flags.count)
+	for i := 0; i < flags.count; i++ {
+		seed := flags.seed + int64(i)
+		name := fuzzName(seed, flags.asm)
+		sources[name] = generate(seed)
+		names = append(names, name)
+
+		path := buildPath(srcDir, name+ext)
+		err := ioutil.WriteFile(path, []byte(sources[name]), 0777)
+		crashOnError(err)
+	}
+	fmt.Println("seeds", flags.seed, "to", flags.seed+int64(flags.count)-1)
+
+	color.Cyan("FUZZING...")
+	testSets := flags.testSets()
+	if flags.asm {
+		batchPipeline(binToolchain, flags.threads, testSets, srcDir, workDir)
+		executeAll(flags.threads,
+			srcDir, asmExt,
+			buildPath(workDir, run, unoptimized), txtExt,
+			"",
+			wine, emulatorArgs)
+	} else {
+		batchPipeline(binToolchain, flags.threads,
+			append([]string{codegenerator}, testSets...),
+			srcDir, workDir)
+	}
+
+	found, invalid := 0, 0
+	for _, name := range names {
+		var reason string
+		if flags.asm {
+			reason = fuzzAsmFailure(workDir, name)
+		} else {
+			if !exists(buildPath(workDir, asm, codegenerator, name+asmExt)) {
+				invalid++
+			}
+			reason = fuzzFailure(workDir, name, testSets)
+		}
+		if reason == "" {
+			continue
+		}
+		found++
+		color.Red(name + ": " + reason)
+		createTest(testPath(name, flags.asm), []byte(sources[name]))
+	}
+
+	green := color.New(color.FgGreen)
+	green.Println("found: [", found, "/", flags.count, "]")
+	if invalid > 0 {
+		color.Yellow(strconv.Itoa(invalid) + " programs did not build with the " +
+			"codegenerator, the fuzz grammar may not match the language")
+	}
+}
+
+// fuzzName is the name of the test generated from seed, so that any test the
+// fuzzer saves can be traced back to the seed which made it
+func fuzzName(seed int64, asm bool) string {
+	if asm {
+		return "fuzz-asm-" + strconv.FormatInt(seed, 10)
+	}
+	return "fuzz-" + strconv.FormatInt(seed, 10)
+}
+
+// fuzzFailure explains what went wrong with a generated test in the pipelines
+// batchPipeline ran, or returns the empty string if nothing did
+//
+// the codegenerator is the oracle: every other pipeline has to run the test
+// to the same output it does
+func fuzzFailure(workDir, testname string, testSets []string) string {
+	reference := readPipelineOutput(codegenerator, workDir, testname)
+	if reason := crashReason(codegenerator, reference); reason != "" {
+		return reason
+	}
+	for _, testSet := range testSets {
+		if testSet == codegenerator {
+			continue
+		}
+		output := readPipelineOutput(testSet, workDir, testname)
+		if reason := crashReason(testSet, output); reason != "" {
+			return reason
+		}
+		if output.run != reference.run {
+			return testSet + " run differs from the codegenerator's"
+		}
+	}
+	return ""
+}
+
+func crashReason(testSet string, output pipelineOutput) string {
+	if exception := findJavaException(output.build); exception != "" {
+		return testSet + " threw " + exception
+	}
+	if timedOut(output.build) || timedOut(output.run) {
+		return testSet + " timed out"
+	}
+	for _, phaseOutput := range []string{output.build, output.run} {
+		if limit := exceededLimit(phaseOutput); limit != "" {
+			return testSet + " exceeded its limit on " + limit
+		}
+	}
+	return ""
+}
+
+// fuzzAsmFailure is fuzzFailure for generated asm, where the oracle is
+// running the asm without optimizing it at all
+func fuzzAsmFailure(workDir, testname string) string {
+	output := readPipelineOutput(optimizerStandalone, workDir, testname)
+	if reason := crashReason(optimizerStandalone, output); reason != "" {
+		return reason
+	}
+	reference := readFile(buildPath(workDir, run, unoptimized, testname+txtExt))
+	if timedOut(reference) {
+		return "unoptimized run timed out"
+	}
+	if limit := exceededLimit(reference); limit != "" {
+		return "unoptimized run exceeded its limit on " + limit
+	}
+	if output.run != reference {
+		return "optimized run differs from the unoptimized run"
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package main
+
+// production is one way of expanding a nonterminal, picked with probability
+// proportional to its weight
+//
+// <name> in a form expands the nonterminal name, the first production of
+// every nonterminal must not recurse, since it is the only one picked once
+// the generator has nested too deeply
+type production struct {
+	weight int
+	form   string
+}
+
+type grammar map[string][]production
+
+// pikaGrammar describes the well typed subset of pika that the fuzzer
+// generates, besides its own nonterminals it uses these built in ones:
+//
+// <stmts>, <block>: a run of statements, at the top level or nested in a block
+// <indent>: indentation for the current block
+// <int-literal>, <nonzero-int-literal>, <float-literal>, <nonzero-float-literal>
+// <int-var>, <float-var>, <bool-var>: any variable in scope of that type
+// <mutable-int-var>, ...: any variable in scope of that type declared with var
+// <new-const-int>, <new-var-int>, ...: declares a new variable of that type,
+// which comes into scope after the statement declaring it
+// <counter>: a fresh name, the same one everywhere within a single form
+var pikaGrammar = grammar{
+	"program": {
+		{1, "exec {\n<stmts>}\n"},
+	},
+	"stmt": {
+		{3, "<indent>print <int> _n_.\n"},
+		{1, "<indent>print <float> _n_.\n"},
+		{1, "<indent>print <bool> _n_.\n"},
+		{3, "<indent>const <new-const-int> := <int>.\n"},
+		{3, "<indent>var <new-var-int> := <int>.\n"},
+		{1, "<indent>const <new-const-float> := <float>.\n"},
+		{1, "<indent>var <new-var-float> := <float>.\n"},
+		{1, "<indent>var <new-var-bool> := <bool>.\n"},
+		{3, "<indent>let <mutable-int-var> := <int>.\n"},
+		{1, "<indent>let <mutable-float-var> := <float>.\n"},
+		{1, "<indent>let <mutable-bool-var> := <bool>.\n"},
+		{2, "<indent>if (<bool>) {\n<block><indent>}\n"},
+		{1, "<indent>if (<bool>) {\n<block><indent>} else {\n<block><indent>}\n"},
+		{1, "<indent>var <counter> := 0.\n" +
+			"<indent>while (<counter> < <loop-bound>) {\n" +
+			"<block>" +
+			"<indent>\tlet <counter> := <counter> + 1.\n" +
+			"<indent>}\n"},
+	},
+	"int": {
+		{4, "<int-literal>"},
+		{4, "<int-var>"},
+		{2, "(<int> + <int>)"},
+		{2, "(<int> - <int>)"},
+		{2, "(<int> * <int>)"},
+		{1, "(<int> / <nonzero-int-literal>)"},
+	},
+	"float": {
+		{4, "<float-literal>"},
+		{4, "<float-var>"},
+		{2, "(<float> + <float>)"},
+		{2, "(<float> - <float>)"},
+		{2, "(<float> * <float>)"},
+		{1, "(<float> / <nonzero-float-literal>)"},
+	},
+	"bool": {
+		{2, "_true_"},
+		{2, "_false_"},
+		{4, "<bool-var>"},
+		{2, "(<int> < <int>)"},
+		{2, "(<int> >= <int>)"},
+		{2, "(<int> == <int>)"},
+		{1, "(<int> != <int>)"},
+		{1, "(<float> > <float>)"},
+		{1, "(<float> <= <float>)"},
+		{1, "(<bool> && <bool>)"},
+		{1, "(<bool> || <bool>)"},
+		{1, "!<bool>"},
+	},
+	"loop-bound": {
+		{1, "<nonzero-int-literal>"},
+	},
+}
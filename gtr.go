@@ -20,16 +20,62 @@ func main() {
 		flags := makeTestFlags(args)
 		testCommand(flags)
 	case "view":
-		flags, target := makeViewFlags(args)
-		viewCommand(flags, target)
+		flags, targets := makeViewFlags(args)
+		viewCommand(flags, targets)
 	case "create":
 		flags, target := makeCreateFlags(args)
 		createCommand(flags, target)
 	case "accept":
-		flags, target := makeAcceptFlags(args)
-		acceptCommand(flags, target)
+		flags, targets := makeAcceptFlags(args)
+		acceptCommand(flags, targets)
+	case "status":
+		statusCommand()
+	case "mv":
+		flags, target, newName := makeMvFlags(args)
+		mvCommand(flags, target, newName)
+	case "rm":
+		flags, target := makeRmFlags(args)
+		rmCommand(flags, target)
+	case "prune":
+		flags := makePruneFlags(args)
+		pruneCommand(flags)
+	case "metrics":
+		flags := makeMetricsFlags(args)
+		metricsCommand(flags)
+	case "bench":
+		flags, targets := makeBenchFlags(args)
+		benchCommand(flags, targets)
+	case "compare-toolchains":
+		flags, targets := makeCompareToolchainsFlags(args)
+		compareToolchainsCommand(flags, targets)
+	case "bisect":
+		flags, target := makeBisectFlags(args)
+		bisectCommand(flags, target)
+	case "bisect-git":
+		flags, target := makeBisectGitFlags(args)
+		bisectGitCommand(flags, target)
+	case "toolchain":
+		flags, subcommand, tools := makeToolchainFlags(args)
+		toolchainCommand(flags, subcommand, tools)
+	case "blame":
+		flags, target := makeBlameFlags(args)
+		blameCommand(flags, target)
+	case "undo":
+		flags, target := makeUndoFlags(args)
+		undoCommand(flags, target)
+	case "review":
+		flags := makeReviewFlags(args)
+		reviewCommand(flags)
+	case "reduce":
+		flags, target := makeReduceFlags(args)
+		reduceCommand(flags, target)
+	case "fuzz":
+		flags := makeFuzzFlags(args)
+		fuzzCommand(flags)
 	case "init":
 		initDirs()
+	case "doctor":
+		doctorCommand()
 	case "help", "-help", "--help":
 		helpMessage()
 		os.Exit(0)
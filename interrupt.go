@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/fatih/color"
+)
+
+// interrupted is cancelled by the first SIGINT or SIGTERM once
+// handleInterrupts is called, killing every child spawned by execute
+var interrupted, interrupt = context.WithCancel(context.Background())
+
+// exit code of a process killed by SIGINT, as shells report it
+const interruptedExitCode = 130
+
+// handleInterrupts has the first SIGINT or SIGTERM stop every tool and let
+// the command wind down, and the second quit immediately
+func handleInterrupts() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		fmt.Println()
+		color.Magenta("interrupted, stopping every tool... interrupt again to quit immediately")
+		interrupt()
+		<-signals
+		os.Exit(interruptedExitCode)
+	}()
+}
+
+func wasInterrupted() bool {
+	return interrupted.Err() != nil
+}
+
+// inProcessGroup has task run in a process group of its own, which is
+// killed as a whole when its context is cancelled, so that nothing it
+// starts outlives it
+func inProcessGroup(task *exec.Cmd) {
+	task.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	task.Cancel = func() error {
+		return syscall.Kill(-task.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// progress is how far a test run has got, so that an interrupted run
+// can say what it finished
+type progress struct {
+	sync.Mutex
+	finished []string
+
+	step    string
+	written int
+	total   int
+}
+
+var runProgress progress
+
+// start moves on to the next step, the last is finished unless the run
+// was interrupted before it could be
+func (p *progress) start(step string) {
+	p.Lock()
+	defer p.Unlock()
+	if p.step != "" {
+		p.finished = append(p.finished, p.step)
+	}
+	p.step, p.written, p.total = step, 0, 0
+}
+
+// expect notes that the current step is to write count more results
+func (p *progress) expect(count int) {
+	p.Lock()
+	defer p.Unlock()
+	p.total += count
+}
+
+// wrote notes that the current step has written another result
+func (p *progress) wrote() {
+	p.Lock()
+	defer p.Unlock()
+	p.written++
+}
+
+// printPartialSummary reports which steps of an interrupted run finished,
+// and how far along the step it was interrupted in got
+func (p *progress) printPartialSummary() {
+	p.Lock()
+	defer p.Unlock()
+	color.Cyan("INTERRUPTED...")
+	if len(p.finished) == 0 {
+		fmt.Println("finished: nothing")
+	} else {
+		fmt.Println("finished:", strings.Join(p.finished, ", "))
+	}
+	if p.step != "" {
+		color.Yellow(fmt.Sprintf("%s: wrote %d of %d results before being interrupted",
+			p.step, p.written, p.total))
+	}
+	fmt.Println("results which were written are complete, the rest were left as they were")
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// transaction states
+const (
+	pending    = "pending"
+	committed  = "committed"
+	rolledBack = "rolled-back"
+	undone     = "undone"
+)
+
+// transaction is a journal entry for a single command which changes
+// expectations, the previous contents of every file it touches are kept
+// alongside it, so that it can be undone however many commands later
+type transaction struct {
+	ID      string    `json:"id"`
+	Command string    `json:"command"`
+	Time    time.Time `json:"time"`
+	State   string    `json:"state"`
+	Changes []change  `json:"changes"`
+
+	// Provenance holds the provenance of each expectation before the
+	// transaction, nil where there was none, and Accepted holds it after
+	Provenance map[string]*provenance `json:"provenance,omitempty"`
+	Accepted   map[string]provenance  `json:"accepted,omitempty"`
+
+	// Renames maps each path the transaction moved to where it moved it,
+	// the rest of the journal follows files as they are renamed
+	Renames map[string]string `json:"renames,omitempty"`
+}
+
+// change replaces path with source, or deletes it when there is no source
+type change struct {
+	Path   string `json:"path"`
+	Source string `json:"source,omitempty"`
+
+	// Snapshot is where path's contents were kept before the change,
+	// it is empty if path didn't exist
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+func beginTransaction(command string) *transaction {
+	now := time.Now()
+	return &transaction{
+		ID:      now.Format("20060102-150405.000000000"),
+		Command: command,
+		Time:    now,
+		State:   pending,
+	}
+}
+
+func (tx *transaction) dir() string {
+	return buildPath(historyDir, tx.ID)
+}
+
+// replace queues moving source over path
+func (tx *transaction) replace(source, path string) {
+	tx.Changes = append(tx.Changes, change{Path: path, Source: source})
+}
+
+// remove queues deleting path
+func (tx *transaction) remove(path string) {
+	tx.Changes = append(tx.Changes, change{Path: path})
+}
+
+// commit snapshots everything the transaction is about to touch, journals
+// it, and only then makes the changes, if any of them fail the ones already
+// made are rolled back so that either all of them happen or none do
+func (tx *transaction) commit() error {
+	if len(tx.Changes) == 0 {
+		return nil
+	}
+	mkdirIfNotExist(buildPath(tx.dir(), "files"))
+	for i := range tx.Changes {
+		if !exists(tx.Changes[i].Path) {
+			continue
+		}
+		snapshot := buildPath(tx.dir(), "files", fmt.Sprint(i))
+		if err := copyFile(tx.Changes[i].Path, snapshot); err != nil {
+			os.RemoveAll(tx.dir())
+			return err
+		}
+		tx.Changes[i].Snapshot = snapshot
+	}
+	if err := tx.save(); err != nil {
+		os.RemoveAll(tx.dir())
+		return err
+	}
+
+	for i, change := range tx.Changes {
+		var err error
+		if change.Source != "" {
+			mkdirIfNotExist(filepath.Dir(change.Path))
+			err = os.Rename(change.Source, change.Path)
+		} else if exists(change.Path) {
+			err = os.Remove(change.Path)
+		}
+		if err != nil {
+			if rollbackErr := rollBack(tx.Changes[:i]); rollbackErr != nil {
+				return fmt.Errorf("%v, then rolling back failed: %v, "+
+					"everything it touched is still kept in %s", err, rollbackErr, tx.dir())
+			}
+			tx.State = rolledBack
+			if saveErr := tx.save(); saveErr != nil {
+				return fmt.Errorf("%v, then journaling the roll back failed: %v", err, saveErr)
+			}
+			return err
+		}
+	}
+
+	tx.State = committed
+	return tx.save()
+}
+
+// undo puts every file the transaction touched back the way it was before
+func (tx *transaction) undo() error {
+	if err := restoreChanges(tx.Changes); err != nil {
+		return err
+	}
+	tx.State = undone
+	return tx.save()
+}
+
+// rollBack reverses the changes a commit made before one of them failed,
+// sources were moved rather than copied, so they are moved back before the
+// snapshots are restored
+func rollBack(applied []change) error {
+	for i := len(applied) - 1; i >= 0; i-- {
+		change := applied[i]
+		if change.Source == "" {
+			continue
+		}
+		mkdirIfNotExist(filepath.Dir(change.Source))
+		if err := os.Rename(change.Path, change.Source); err != nil {
+			return err
+		}
+	}
+	return restoreChanges(applied)
+}
+
+// restoreChanges puts the files changed back to their snapshots, every
+// snapshot is copied next to its destination before any of them are renamed
+// into place, so a failed copy leaves everything untouched
+func restoreChanges(changes []change) error {
+	staged := map[string]string{}
+	for _, change := range changes {
+		if change.Snapshot == "" {
+			continue
+		}
+		temp := change.Path + ".gtr-restore"
+		mkdirIfNotExist(filepath.Dir(change.Path))
+		if err := copyFile(change.Snapshot, temp); err != nil {
+			for _, path := range staged {
+				os.Remove(path)
+			}
+			return err
+		}
+		staged[change.Path] = temp
+	}
+
+	for i := len(changes) - 1; i >= 0; i-- {
+		path := changes[i].Path
+		if temp, ok := staged[path]; ok {
+			if err := os.Rename(temp, path); err != nil {
+				return err
+			}
+		} else if exists(path) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// move queues moving path to newPath, undoing it moves it back again
+func (tx *transaction) move(path, newPath string) {
+	tx.replace(path, newPath)
+	tx.remove(path)
+	if tx.Renames == nil {
+		tx.Renames = map[string]string{}
+	}
+	tx.Renames[path] = newPath
+}
+
+// followRenames points every other transaction in the journal at the new
+// paths of files tx renamed, or back at the old ones once tx is undone, so
+// that their history stays attached to the files
+func followRenames(tx *transaction) {
+	if len(tx.Renames) == 0 {
+		return
+	}
+	renames := tx.Renames
+	if tx.State == undone {
+		renames = map[string]string{}
+		for path, newPath := range tx.Renames {
+			renames[newPath] = path
+		}
+	}
+
+	for _, other := range readHistory() {
+		if other.ID == tx.ID {
+			continue
+		}
+		changed := false
+		for i, change := range other.Changes {
+			if newPath, ok := renames[change.Path]; ok {
+				other.Changes[i].Path = newPath
+				changed = true
+			}
+		}
+		for path, newPath := range renames {
+			if record, ok := other.Provenance[path]; ok {
+				delete(other.Provenance, path)
+				other.Provenance[newPath] = record
+				changed = true
+			}
+			if record, ok := other.Accepted[path]; ok {
+				delete(other.Accepted, path)
+				other.Accepted[newPath] = record
+				changed = true
+			}
+		}
+		if changed {
+			other.save()
+		}
+	}
+}
+
+// touches reports whether the transaction changed path
+func (tx *transaction) touches(path string) bool {
+	for _, change := range tx.Changes {
+		if change.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (tx *transaction) save() error {
+	contents, err := json.MarshalIndent(tx, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(buildPath(tx.dir(), journalFile), contents, 0666)
+}
+
+// readHistory loads every transaction in the journal, oldest first
+func readHistory() []*transaction {
+	if !exists(historyDir) {
+		return nil
+	}
+	history := []*transaction{}
+	for _, dir := range getAllFiles(historyDir) {
+		contents := readFile(buildPath(historyDir, dir.Name(), journalFile))
+		if contents == "" {
+			continue
+		}
+		tx := &transaction{}
+		if err := json.Unmarshal([]byte(contents), tx); err != nil {
+			continue
+		}
+		history = append(history, tx)
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].ID < history[j].ID
+	})
+	return history
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// umask is the file mode creation mask gtr was started with, which
+// writeFileAtomic applies itself, as its temporary files are always private
+var umask = func() os.FileMode {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return os.FileMode(mask)
+}()
+
+// writeFileAtomic is ioutil.WriteFile, but writes to a temporary file next to
+// path, and renames it into place, so that path is never left half written
+func writeFileAtomic(path string, contents []byte, perm os.FileMode) error {
+	temp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if err := temp.Chmod(perm &^ umask); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	if _, err := temp.Write(contents); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// resourceLimits bound what each child spawned by execute may use,
+// zero leaves that resource unbounded
+type resourceLimits struct {
+	memoryMB int
+	cpu      time.Duration
+	outputMB int
+	files    int
+}
+
+// executeLimits are the limits every child spawned by execute runs under
+var executeLimits = resourceLimits{outputMB: defaultMaxOutputMB}
+
+// the names of each limit, as reported when a child exceeds it
+const (
+	memoryLimit = "memory"
+	cpuLimit    = "cpu time"
+	outputLimit = "output"
+	filesLimit  = "open files"
+)
+
+// wrap applies the limits enforced by the kernel to cmd, by having a shell
+// set them before replacing itself with cmd, the output limit is enforced
+// by execute as it collects the output
+func (limits resourceLimits) wrap(cmd string, args []string) (string, []string) {
+	ulimits := []string{}
+	if limits.memoryMB > 0 {
+		ulimits = append(ulimits, fmt.Sprint("ulimit -v ", limits.memoryMB*1024))
+	}
+	if limits.cpu > 0 {
+		// the hard limit is a second past the soft limit, so that the
+		// child is sent SIGXCPU before it is sent SIGKILL
+		seconds := limits.cpuSeconds()
+		ulimits = append(ulimits, fmt.Sprint("ulimit -S -t ", seconds),
+			fmt.Sprint("ulimit -H -t ", seconds+1))
+	}
+	if limits.files > 0 {
+		ulimits = append(ulimits, fmt.Sprint("ulimit -n ", limits.files))
+	}
+	if len(ulimits) == 0 {
+		return cmd, args
+	}
+	script := strings.Join(ulimits, " && ") + ` && exec "$0" "$@"`
+	return "sh", append([]string{"-c", script, cmd}, args...)
+}
+
+// cpuSeconds is the cpu limit as ulimit counts it, in whole seconds,
+// rounding up rather than down to nothing
+func (limits resourceLimits) cpuSeconds() int64 {
+	return int64((limits.cpu + time.Second - 1) / time.Second)
+}
+
+// what the jvm and wine print when they run out of memory or file
+// descriptors, neither has a more reliable way of telling us
+var (
+	memoryErrors = []string{
+		"OutOfMemoryError",
+		"Could not reserve enough space",
+		"Cannot allocate memory",
+		"insufficient memory",
+	}
+	filesErrors = []string{
+		"Too many open files",
+	}
+)
+
+// exceeded names the limit a finished child ran into, if any
+func (limits resourceLimits) exceeded(task *exec.Cmd, collected *limitedOutput) string {
+	if collected.exceeded {
+		return outputLimit
+	}
+	state := task.ProcessState
+	if state == nil || state.ExitCode() == 0 {
+		return ""
+	}
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && limits.cpu > 0 && status.Signaled() {
+		// SIGKILL follows SIGXCPU if the child handles or ignores it
+		used := state.UserTime() + state.SystemTime()
+		if status.Signal() == syscall.SIGXCPU ||
+			status.Signal() == syscall.SIGKILL && used >= time.Duration(limits.cpuSeconds())*time.Second {
+			return cpuLimit
+		}
+	}
+	output := string(collected.everything())
+	if limits.memoryMB > 0 && containsAny(output, memoryErrors) {
+		return memoryLimit
+	}
+	if limits.files > 0 && containsAny(output, filesErrors) {
+		return filesLimit
+	}
+	return ""
+}
+
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedOutput collects everything a child prints to stdout and stderr, up
+// to a combined limit, after which it drops the rest and calls kill
+type limitedOutput struct {
+	sync.Mutex
+	limit    int
+	written  int
+	exceeded bool
+	kill     func()
+
+	// quietStderr keeps stderr out of the output, it is still collected to
+	// find the limit a child ran into
+	quietStderr bool
+
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+}
+
+func newLimitedOutput(limitMB int, kill func()) *limitedOutput {
+	return &limitedOutput{limit: limitMB * 1024 * 1024, kill: kill}
+}
+
+// limitedWriter is one of the streams feeding a limitedOutput
+type limitedWriter struct {
+	collected *limitedOutput
+	buffer    *bytes.Buffer
+}
+
+func (collected *limitedOutput) stdoutWriter() *limitedWriter {
+	return &limitedWriter{collected, &collected.stdout}
+}
+
+func (collected *limitedOutput) stderrWriter() *limitedWriter {
+	return &limitedWriter{collected, &collected.stderr}
+}
+
+// Write always claims to have written everything, so that the child isn't
+// sent a broken pipe before it can be killed
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	collected := w.collected
+	collected.Lock()
+	defer collected.Unlock()
+	if collected.exceeded {
+		return len(p), nil
+	}
+	kept := p
+	if collected.limit > 0 && collected.written+len(p) > collected.limit {
+		kept = p[:collected.limit-collected.written]
+		collected.exceeded = true
+		collected.kill()
+	}
+	collected.written += len(kept)
+	w.buffer.Write(kept)
+	return len(p), nil
+}
+
+// bytes is the output collected, stdout first
+func (collected *limitedOutput) bytes() []byte {
+	if collected.quietStderr {
+		collected.Lock()
+		defer collected.Unlock()
+		return append([]byte{}, collected.stdout.Bytes()...)
+	}
+	return collected.everything()
+}
+
+// everything is everything collected, stdout first, even a quiet stderr
+func (collected *limitedOutput) everything() []byte {
+	collected.Lock()
+	defer collected.Unlock()
+	return append(append([]byte{}, collected.stdout.Bytes()...), collected.stderr.Bytes()...)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// asmMetrics are the static metrics of a single asm output, as stored
+// ASMEmu doesn't report how many instructions it executes, so only the
+// instructions in the program can be counted, not how often each one runs
+type asmMetrics struct {
+	Instructions int `json:"instructions"`
+	Cycles       int `json:"cycles"`
+	Jumps        int `json:"jumps"`
+	MemoryOps    int `json:"memory_ops"`
+	CodeSize     int `json:"code_size"`
+}
+
+// metric is a single named value of asmMetrics
+type metric struct {
+	name  string
+	value int
+}
+
+func (metrics asmMetrics) list() []metric {
+	return []metric{
+		{"instructions", metrics.Instructions},
+		{"est. cycles", metrics.Cycles},
+		{"jumps", metrics.Jumps},
+		{"memory ops", metrics.MemoryOps},
+		{"code size", metrics.CodeSize},
+	}
+}
+
+func measureAsm(path string) asmMetrics {
+	stats := countAsm(parseAsm(readFile(path)))
+	return asmMetrics{
+		Instructions: stats.instructions,
+		Cycles:       stats.cycles,
+		Jumps:        stats.jumps,
+		MemoryOps:    stats.memoryOps,
+		CodeSize:     stats.size,
+	}
+}
+
+// metricsKey identifies an output the same way known failures do,
+// as: <test set> <phase> <test name>
+func metricsKey(output testOutput) string {
+	return output.testSet + " " + output.phase + " " + output.testname
+}
+
+func metricsOutput(key string) testOutput {
+	fields := strings.Fields(key)
+	if len(fields) != 3 {
+		return testOutput{}
+	}
+	return testOutput{testSet: fields[0], phase: fields[1], testname: fields[2]}
+}
+
+// updateMetrics measures the asm every test in testSets produced, replacing
+// whatever was measured for those sets before
+func updateMetrics(testSets []string) {
+	metrics := readMetrics(metricsFile)
+	for key := range metrics {
+		if containsString(testSets, metricsOutput(key).testSet) {
+			delete(metrics, key)
+		}
+	}
+	for _, testSet := range testSets {
+		for _, testname := range testNames(testSet) {
+			for _, phase := range []string{asm, asmo} {
+				output := testOutput{testname, testSet, phase}
+				if exists(output.resultPath()) {
+					metrics[metricsKey(output)] = measureAsm(output.resultPath())
+				}
+			}
+		}
+	}
+	mkdirIfNotExist(gtrDir)
+	writeMetrics(metricsFile, metrics)
+}
+
+func readMetrics(path string) map[string]asmMetrics {
+	metrics := map[string]asmMetrics{}
+	contents := readFile(path)
+	if contents != "" {
+		json.Unmarshal([]byte(contents), &metrics)
+	}
+	return metrics
+}
+
+func writeMetrics(path string, metrics map[string]asmMetrics) {
+	contents, err := json.MarshalIndent(metrics, "", "\t")
+	crashOnError(err)
+	crashOnError(writeFileAtomic(path, contents, 0666))
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/fatih/color"
+)
+
+// metricsCommand compares the metrics of the last run against the baseline,
+// flagging every output the optimizer or compiler made worse
+func metricsCommand(flags metricsFlags) {
+	current := readMetrics(metricsFile)
+	for key := range current {
+		if flags.testSet != "" && metricsOutput(key).testSet != flags.testSet {
+			delete(current, key)
+		}
+	}
+	if len(current) == 0 {
+		color.Magenta("no metrics have been recorded, " +
+			"run gtr test with -optimize, -compile or -optimize-standalone first")
+		os.Exit(1)
+		return
+	}
+
+	baseline := readMetrics(metricsBaselineFile)
+	if flags.save {
+		for key, metrics := range current {
+			baseline[key] = metrics
+		}
+		mkdirIfNotExist(expectDir)
+		writeMetrics(metricsBaselineFile, baseline)
+		fmt.Println("saved the metrics of", len(current), "outputs as the baseline")
+		return
+	}
+
+	keys := make([]string, 0, len(current))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	regressed, improved, unmeasured := 0, 0, 0
+	var totalBefore, totalAfter asmMetrics
+	for _, key := range keys {
+		before, ok := baseline[key]
+		if !ok {
+			unmeasured++
+			continue
+		}
+		after := current[key]
+		totalBefore = addMetrics(totalBefore, before)
+		totalAfter = addMetrics(totalAfter, after)
+
+		worse, better := compareMetrics(before, after)
+		if worse {
+			regressed++
+			color.Red("worse: " + metricsOutput(key).String())
+			printMetricChanges(before, after)
+		} else if better {
+			improved++
+			if flags.verbose {
+				color.Green("better: " + metricsOutput(key).String())
+				printMetricChanges(before, after)
+			}
+		}
+	}
+
+	if totalBefore != (asmMetrics{}) {
+		color.Cyan("TOTALS...")
+		fmt.Printf("%-16s %10s %10s %16s\n", "", "baseline", "current", "change")
+		beforeList, afterList := totalBefore.list(), totalAfter.list()
+		for i := range beforeList {
+			printStatChange(beforeList[i].name, beforeList[i].value, afterList[i].value)
+		}
+	}
+
+	fmt.Println()
+	red := color.New(color.FgRed)
+	green := color.New(color.FgGreen)
+	red.Println("worse:  [", regressed, "/", len(keys)-unmeasured, "]")
+	green.Println("better: [", improved, "/", len(keys)-unmeasured, "]")
+	if unmeasured > 0 {
+		color.Yellow(fmt.Sprint(unmeasured, " outputs aren't in the baseline, ") +
+			"add them with gtr metrics -save")
+	}
+	if regressed > 0 {
+		os.Exit(1)
+	}
+}
+
+// compareMetrics reports whether any metric got worse, or, failing that,
+// whether any got better
+func compareMetrics(before, after asmMetrics) (worse, better bool) {
+	beforeList, afterList := before.list(), after.list()
+	for i := range beforeList {
+		if afterList[i].value > beforeList[i].value {
+			worse = true
+		} else if afterList[i].value < beforeList[i].value {
+			better = true
+		}
+	}
+	return worse, better
+}
+
+// printMetricChanges lists just the metrics which changed
+func printMetricChanges(before, after asmMetrics) {
+	beforeList, afterList := before.list(), after.list()
+	for i := range beforeList {
+		if beforeList[i].value != afterList[i].value {
+			fmt.Print("\t")
+			printStatChange(beforeList[i].name, beforeList[i].value, afterList[i].value)
+		}
+	}
+}
+
+func addMetrics(a, b asmMetrics) asmMetrics {
+	return asmMetrics{
+		Instructions: a.Instructions + b.Instructions,
+		Cycles:       a.Cycles + b.Cycles,
+		Jumps:        a.Jumps + b.Jumps,
+		MemoryOps:    a.MemoryOps + b.MemoryOps,
+		CodeSize:     a.CodeSize + b.CodeSize,
+	}
+}
@@ -9,73 +9,21 @@ import (
 	"os/exec"
 	"runtime"
 	"strings"
+	"time"
 )
 
 func initDirs() {
-	initBinDir()
-	initSourceDirs()
-	initExpectDirs()
-	initResultDirs()
-}
-
-func initBinDir() {
-	mkdirIfNotExist(binDir)
-}
-
-func initSourceDirs() {
-	mkdirIfNotExist(pikaDir)
-	mkdirIfNotExist(asmDir)
-}
-
-// TODO reoptimize
-func initResultDirs() {
-	mkdirIfNotExist(result.asm.codegenerator)
-	mkdirIfNotExist(result.asm.compiler)
-	mkdirIfNotExist(result.asm.optimizer)
-	mkdirIfNotExist(result.asm.optimizerStandalone)
-
-	mkdirIfNotExist(result.asmo.compiler)
-	mkdirIfNotExist(result.asmo.optimizer)
-	mkdirIfNotExist(result.asmo.optimizerStandalone)
-
-	mkdirIfNotExist(result.build.codegenerator)
-	mkdirIfNotExist(result.build.compiler)
-	mkdirIfNotExist(result.build.optimizer)
-	mkdirIfNotExist(result.build.optimizerStandalone)
-
-	mkdirIfNotExist(result.buildo.compiler)
-	mkdirIfNotExist(result.buildo.optimizer)
-	mkdirIfNotExist(result.buildo.optimizerStandalone)
-
-	mkdirIfNotExist(result.run.codegenerator)
-	mkdirIfNotExist(result.run.compiler)
-	mkdirIfNotExist(result.run.optimizer)
-	mkdirIfNotExist(result.run.optimizerStandalone)
+	for _, dir := range requiredDirs() {
+		mkdirIfNotExist(dir)
+	}
 }
 
-func initExpectDirs() {
-	mkdirIfNotExist(expect.asm.codegenerator)
-	mkdirIfNotExist(expect.asm.compiler)
-	mkdirIfNotExist(expect.asm.optimizer)
-	mkdirIfNotExist(expect.asm.optimizerStandalone)
-
-	mkdirIfNotExist(expect.asmo.compiler)
-	mkdirIfNotExist(expect.asmo.optimizer)
-	mkdirIfNotExist(expect.asmo.optimizerStandalone)
-
-	mkdirIfNotExist(expect.build.codegenerator)
-	mkdirIfNotExist(expect.build.compiler)
-	mkdirIfNotExist(expect.build.optimizer)
-	mkdirIfNotExist(expect.build.optimizerStandalone)
-
-	mkdirIfNotExist(expect.buildo.compiler)
-	mkdirIfNotExist(expect.buildo.optimizer)
-	mkdirIfNotExist(expect.buildo.optimizerStandalone)
-
-	mkdirIfNotExist(expect.run.codegenerator)
-	mkdirIfNotExist(expect.run.compiler)
-	mkdirIfNotExist(expect.run.optimizer)
-	mkdirIfNotExist(expect.run.optimizerStandalone)
+// requiredDirs are every directory gtr expects to find in the directory it's
+// run in
+func requiredDirs() []string {
+	dirs := []string{binDir, pikaDir, asmDir}
+	dirs = append(dirs, expect.dirs()...)
+	return append(dirs, result.dirs()...)
 }
 
 func cleanResultDirs() {
@@ -128,6 +76,31 @@ func exists(path string) bool {
 	return err == nil
 }
 
+func modTime(path string) (time.Time, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
+
+// newer reports whether path was modified after other
+func newer(path, other string) bool {
+	pathTime, ok := modTime(path)
+	otherTime, otherOk := modTime(other)
+	return ok && otherOk && pathTime.After(otherTime)
+}
+
+// readFile returns the contents of path, or the empty string if it doesn't exist
+func readFile(path string) string {
+	if !exists(path) {
+		return ""
+	}
+	contents, err := ioutil.ReadFile(path)
+	crashOnError(err)
+	return string(contents)
+}
+
 func getAllFiles(dir string) []os.FileInfo {
 	files, err := ioutil.ReadDir(dir)
 	crashOnError(err)
@@ -141,6 +114,14 @@ func buildPath(parts ...string) string {
 	return strings.Join(parts, "/")
 }
 
+// testPath is where the source of a test lives
+func testPath(testname string, asm bool) string {
+	if asm {
+		return buildPath(asmDir, testname+asmExt)
+	}
+	return buildPath(pikaDir, testname+pikaExt)
+}
+
 func replaceExtension(filename string, ext string) string {
 	prefix := strings.Split(filename, ".")[0]
 	return prefix + ext
@@ -191,11 +172,42 @@ func helpMessage() {
 	fmt.Println("test:\t\trun tests")
 	fmt.Println("create:\t\tcreate a new test, requires test name as <target>")
 	fmt.Println("view:\t\tview a specified test's results, " +
-		"requires test name as <target>")
+		"requires test names or globs as <target>s, unless using -failed")
 	fmt.Println("accept:\t\taccept the current output of a test in the future, " +
 		"may require test name as <target>")
+	fmt.Println("status:\t\tlist tests without expectations, stale results, " +
+		"and expectations whose test was deleted")
+	fmt.Println("mv:\t\trename a test along with its expectations and results, " +
+		"requires the old and new names as <target>s")
+	fmt.Println("rm:\t\tdelete a test along with its expectations and results, " +
+		"requires test name as <target>")
+	fmt.Println("prune:\t\tdelete expectations and results whose test no longer exists")
+	fmt.Println("metrics:\tcompare instruction counts, jumps, memory ops and code size " +
+		"of the last run's asm against the baseline")
+	fmt.Println("bench:\t\ttime repeated runs of each test, flagging those " +
+		"significantly slower than the baseline, may take globs as <target>s")
+	fmt.Println("compare-toolchains:\n\t\trun tests through the jars in -a and -b, " +
+		"showing every output which differs, may take globs as <target>s")
+	fmt.Println("bisect:\t\tfind the first of a directory of jar snapshots in which " +
+		"a test fails, requires test name as <target>")
+	fmt.Println("bisect-git:\tfind the commit which broke a test, rebuilding the tool " +
+		"into bin at each step of a git bisect, requires test name as <target>")
+	fmt.Println("toolchain:\tcheck the tools in bin against the pinned toolchain, " +
+		"or pin them with toolchain pin, which may take tool names as <target>s")
+	fmt.Println("blame:\t\tshow who accepted each expectation of a test, " +
+		"when, and with which tools, requires test name as <target>")
+	fmt.Println("undo:\t\troll back an accept, mv, rm or prune, the latest unless given its id " +
+		"as <target>")
+	fmt.Println("reduce:\t\tshrink a failing test down to a minimal reproducer, " +
+		"requires test name as <target>")
+	fmt.Println("fuzz:\t\tgenerate random tests, keeping those which crash, " +
+		"hang, or run differently to the codegenerator")
+	fmt.Println("review:\t\tstep through every failing result, " +
+		"accepting or skipping each one with a single key")
 	fmt.Println("init:\t\tbuild the directory structure needed to run gtr in " +
 		"this directory")
+	fmt.Println("doctor:\t\tcheck that java, wine, the tools in bin and the directory " +
+		"structure are all in working order")
 	fmt.Println()
 	fmt.Println("see gtr <command> --help for details on that command's flags")
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/fatih/color"
+)
+
+// mvCommand renames a test along with every expectation and result it has,
+// keeping the provenance and journal history of its expectations attached
+func mvCommand(flags mvFlags, testname, newName string) {
+	path := testPath(testname, flags.asm)
+	newPath := testPath(newName, flags.asm)
+	if !exists(path) {
+		color.Magenta(path + " does not exist")
+		os.Exit(1)
+		return
+	}
+	if exists(newPath) {
+		color.Magenta(newPath + " already exists")
+		os.Exit(1)
+		return
+	}
+
+	tx := beginTransaction("mv " + testname + " " + newName)
+	tx.move(path, newPath)
+	moved := 0
+	for _, output := range testOutputs(testname, flags.asm) {
+		renamed := testOutput{newName, output.testSet, output.phase}
+		if exists(output.expectPath()) {
+			tx.move(output.expectPath(), renamed.expectPath())
+			moved++
+		}
+		if exists(output.resultPath()) {
+			tx.move(output.resultPath(), renamed.resultPath())
+		}
+	}
+	renameKnownFailures(tx, testname, newName, flags.asm)
+	commitTreeChange(tx, "mv")
+
+	color.Green("moved " + path + " to " + newPath)
+	fmt.Println("moved", moved, "expectations, undo with: gtr undo", tx.ID)
+}
+
+// commitTreeChange commits tx, carrying the provenance of every expectation
+// it moves or deletes along with it
+func commitTreeChange(tx *transaction, command string) {
+	keepProvenance(tx)
+	err := tx.commit()
+	if err != nil {
+		color.Magenta(command + " failed, nothing was changed: " + err.Error())
+		os.Exit(1)
+	}
+	applyProvenance(tx)
+	followRenames(tx)
+}
+
+// renameKnownFailures queues moving any known failures of testname over to
+// newName in tx, or forgetting them when newName is empty, so that undoing tx
+// brings them back
+func renameKnownFailures(tx *transaction, testname, newName string, asm bool) {
+	known := readKnownFailures()
+	changed := false
+	for output := range known {
+		if output.testname != testname || (output.testSet == optimizerStandalone) != asm {
+			continue
+		}
+		delete(known, output)
+		if newName != "" {
+			known[testOutput{newName, output.testSet, output.phase}] = true
+		}
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	staged := buildPath(tx.dir(), filepath.Base(knownFailuresFile))
+	mkdirIfNotExist(tx.dir())
+	crashOnError(ioutil.WriteFile(staged, knownFailuresContents(known), 0666))
+	tx.replace(staged, knownFailuresFile)
+}
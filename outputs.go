@@ -0,0 +1,167 @@
+package main
+
+import (
+	"strings"
+)
+
+// these are vars, but just as a technical restriction
+// they should be considered constants
+var (
+	testSets = []string{codegenerator, compiler, optimizer, optimizerStandalone}
+	phases   = []string{build, asm, run, buildo, asmo}
+)
+
+// testOutput is a single phase of a single test in a single set
+type testOutput struct {
+	testname string
+	testSet  string
+	phase    string
+}
+
+func (output testOutput) String() string {
+	return output.testname + " (" + output.testSet + ", " + output.phase + ")"
+}
+
+func (output testOutput) resultPath() string {
+	return outputPath(result, output.phase, output.testSet, output.testname)
+}
+
+func (output testOutput) expectPath() string {
+	return outputPath(expect, output.phase, output.testSet, output.testname)
+}
+
+// outputPath is where tree keeps the output of a test for a phase,
+// it is empty when testSet has no such phase
+func outputPath(tree testDirTree, phase, testSet, testname string) string {
+	dir := tree.dir(phase, testSet)
+	if dir == "" {
+		return ""
+	}
+	return buildPath(dir, testname+outputExt(phase, testSet))
+}
+
+// outputExt is the extension of the files produced by phase for testSet
+func outputExt(phase, testSet string) string {
+	switch phase {
+	case asm:
+		if testSet == optimizer || testSet == optimizerStandalone {
+			return asmoExt
+		}
+		return asmExt
+	case asmo:
+		return asmoExt
+	}
+	return txtExt
+}
+
+// sourceDir is where the tests for testSet live
+func sourceDir(testSet string) string {
+	if testSet == optimizerStandalone {
+		return asmDir
+	}
+	return pikaDir
+}
+
+func sourceExt(testSet string) string {
+	if testSet == optimizerStandalone {
+		return asmExt
+	}
+	return pikaExt
+}
+
+// testNames lists every test in testSet, without extensions
+func testNames(testSet string) []string {
+	files := getAllFiles(sourceDir(testSet))
+	files = filterFiles(files, sourceExt(testSet))
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), sourceExt(testSet)) {
+			names = append(names, replaceExtension(file.Name(), ""))
+		}
+	}
+	return names
+}
+
+// failingOutputs lists every phase of every test in testSets whose result
+// doesn't match its expectation
+func failingOutputs(testSets []string) []testOutput {
+	failing := []testOutput{}
+	for _, testSet := range testSets {
+		for _, testname := range testNames(testSet) {
+			for _, phase := range phases {
+				output := testOutput{testname, testSet, phase}
+				if output.resultPath() == "" {
+					continue
+				}
+				if !compareResult(output.resultPath(), output.expectPath()) {
+					failing = append(failing, output)
+				}
+			}
+		}
+	}
+	return failing
+}
+
+// outputDir is one of the directories in a testDirTree
+type outputDir struct {
+	phase   string
+	testSet string
+	path    string
+}
+
+// outputDirs lists every directory in tree which exists on disk
+func outputDirs(tree testDirTree) []outputDir {
+	dirs := []outputDir{}
+	for _, testSet := range testSets {
+		for _, phase := range phases {
+			path := tree.dir(phase, testSet)
+			if path != "" && exists(path) {
+				dirs = append(dirs, outputDir{phase, testSet, path})
+			}
+		}
+	}
+	return dirs
+}
+
+// storedOutputs lists every output stored in tree, whether or not the test
+// it belongs to still exists
+func storedOutputs(tree testDirTree) []testOutput {
+	outputs := []testOutput{}
+	for _, dir := range outputDirs(tree) {
+		files := getAllFiles(dir.path)
+		files = filterOutFiles(files, ".gitignore")
+		for _, file := range files {
+			if file.IsDir() || !strings.HasSuffix(file.Name(), outputExt(dir.phase, dir.testSet)) {
+				continue
+			}
+			testname := strings.TrimSuffix(file.Name(), outputExt(dir.phase, dir.testSet))
+			outputs = append(outputs, testOutput{testname, dir.testSet, dir.phase})
+		}
+	}
+	return outputs
+}
+
+// testOutputs lists every phase of every set a test is run in, whether or not
+// it has any output stored for them
+func testOutputs(testname string, asm bool) []testOutput {
+	sets := []string{codegenerator, optimizer, compiler}
+	if asm {
+		sets = []string{optimizerStandalone}
+	}
+	outputs := []testOutput{}
+	for _, testSet := range sets {
+		for _, phase := range phases {
+			output := testOutput{testname, testSet, phase}
+			if output.resultPath() != "" {
+				outputs = append(outputs, output)
+			}
+		}
+	}
+	return outputs
+}
+
+// sourcePath is where the source of the test an output belongs to lives
+func (output testOutput) sourcePath() string {
+	return testPath(output.testname, output.testSet == optimizerStandalone)
+}
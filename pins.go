@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/fatih/color"
+)
+
+// pin is the build of a tool everyone is meant to be testing with
+type pin struct {
+	SHA256  string `json:"sha256"`
+	Version string `json:"version,omitempty"`
+}
+
+// pinnedTools are the tools in bin the toolchain manifest can pin
+var pinnedTools = []string{compilerName, codegeneratorName, optimizerName, emulatorName}
+
+// readPins loads the toolchain manifest, keyed by the name of each tool,
+// it is empty if nothing has been pinned, a manifest which can't be read
+// isn't taken to pin nothing, as that would let anything through
+func readPins() map[string]pin {
+	pins := map[string]pin{}
+	contents := readFile(toolchainManifestFile)
+	if contents == "" {
+		return pins
+	}
+	if err := json.Unmarshal([]byte(contents), &pins); err != nil {
+		color.Magenta(toolchainManifestFile + " is damaged, fix it before testing or pinning: " + err.Error())
+		os.Exit(1)
+	}
+	return pins
+}
+
+func writePins(pins map[string]pin) {
+	contents, err := json.MarshalIndent(pins, "", "\t")
+	crashOnError(err)
+	crashOnError(writeFileAtomic(toolchainManifestFile, contents, 0666))
+}
+
+// unpinnedTools lists every pinned tool whose hash in hashes, as taken by
+// hashTools, differs from its pin, with a line describing each
+func unpinnedTools(pins map[string]pin, hashes map[string]string) []string {
+	differing := []string{}
+	for tool, pinned := range pins {
+		hash := hashes[tool]
+		if hash == pinned.SHA256 {
+			continue
+		}
+		line := tool + ": pinned at " + pinned.String()
+		if hash == "missing" {
+			line += ", but it is missing from " + binDir
+		} else {
+			line += fmt.Sprint(", but ", binDir, " has ", shortHash(hash))
+		}
+		differing = append(differing, line)
+	}
+	sort.Strings(differing)
+	return differing
+}
+
+// missingPins lists every tool in bin, by its hash in hashes, which has no
+// pin, with a line describing each
+func missingPins(pins map[string]pin, hashes map[string]string) []string {
+	missing := []string{}
+	for _, tool := range pinnedTools {
+		if _, ok := pins[tool]; !ok && hashes[tool] != "missing" {
+			missing = append(missing, tool+": in "+binDir+", but not pinned")
+		}
+	}
+	return missing
+}
+
+func (pinned pin) String() string {
+	if pinned.Version == "" {
+		return shortHash(pinned.SHA256)
+	}
+	return pinned.Version + " (" + shortHash(pinned.SHA256) + ")"
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// pipelineOutput is what a single test source produced in each phase
+type pipelineOutput struct {
+	build string
+	asm   string
+	run   string
+}
+
+func (output pipelineOutput) phase(phase string) string {
+	switch phase {
+	case build:
+		return output.build
+	case asm:
+		return output.asm
+	case run:
+		return output.run
+	}
+	return ""
+}
+
+// runPipeline pushes one test source through the tools of testSet, the same
+// way the batch tasks do, keeping every intermediate file under workDir
+func runPipeline(tools toolchain, testSet, srcPath, workDir string) pipelineOutput {
+	srcDir := buildPath(workDir, "src")
+	mkdirIfNotExist(srcDir)
+	filename := filepath.Base(srcPath)
+	err := ioutil.WriteFile(buildPath(srcDir, filename), []byte(readFile(srcPath)), 0777)
+	crashOnError(err)
+
+	batchPipeline(tools, 1, []string{testSet}, srcDir, workDir)
+	return readPipelineOutput(testSet, workDir, replaceExtension(filename, ""))
+}
+
+// batchPipeline runs every source in srcDir through the tools of each of
+// testSets, the same way the batch tasks do, with workDir standing in for
+// the result directory
+func batchPipeline(tools toolchain, count int, testSets []string, srcDir, workDir string) {
+	dir := func(phase, testSet string) string {
+		path := buildPath(workDir, phase, testSet)
+		mkdirIfNotExist(path)
+		return path
+	}
+	wants := map[string]bool{}
+	for _, testSet := range testSets {
+		wants[testSet] = true
+	}
+
+	if wants[codegenerator] || wants[optimizer] {
+		executeAll(count,
+			srcDir, pikaExt,
+			dir(build, codegenerator), txtExt,
+			dir(asm, codegenerator),
+			java, tools.codegeneratorArgs())
+	}
+	if wants[codegenerator] {
+		executeAll(count,
+			dir(asm, codegenerator), asmExt,
+			dir(run, codegenerator), txtExt,
+			"",
+			wine, tools.emulatorArgs())
+	}
+	if wants[optimizer] {
+		executeAll(count,
+			dir(asm, codegenerator), asmExt,
+			dir(build, optimizer), txtExt,
+			dir(asm, optimizer),
+			java, tools.optimizerArgs())
+		executeAll(count,
+			dir(asm, optimizer), asmoExt,
+			dir(run, optimizer), txtExt,
+			"",
+			wine, tools.emulatorArgs())
+	}
+	if wants[compiler] {
+		executeAll(count,
+			srcDir, pikaExt,
+			dir(build, compiler), txtExt,
+			dir(asm, compiler),
+			java, tools.compilerArgs())
+		executeAll(count,
+			dir(asm, compiler), asmExt,
+			dir(run, compiler), txtExt,
+			"",
+			wine, tools.emulatorArgs())
+	}
+	if wants[optimizerStandalone] {
+		executeAll(count,
+			srcDir, asmExt,
+			dir(build, optimizerStandalone), txtExt,
+			dir(asm, optimizerStandalone),
+			java, tools.optimizerArgs())
+		executeAll(count,
+			dir(asm, optimizerStandalone), asmoExt,
+			dir(run, optimizerStandalone), txtExt,
+			"",
+			wine, tools.emulatorArgs())
+	}
+}
+
+// readPipelineOutput collects what batchPipeline left in workDir for a test,
+// when the optimizer never ran because there was no asm to give it, the
+// codegenerator's build output stands in for its own
+func readPipelineOutput(testSet, workDir, testname string) pipelineOutput {
+	ext := asmExt
+	if testSet == optimizer || testSet == optimizerStandalone {
+		ext = asmoExt
+	}
+	output := pipelineOutput{
+		build: readFile(buildPath(workDir, build, testSet, testname+txtExt)),
+		asm:   readFile(buildPath(workDir, asm, testSet, testname+ext)),
+		run:   readFile(buildPath(workDir, run, testSet, testname+txtExt)),
+	}
+	unoptimized := buildPath(workDir, asm, codegenerator, testname+asmExt)
+	if testSet == optimizer && !exists(unoptimized) {
+		output.build = readFile(buildPath(workDir, build, codegenerator, testname+txtExt))
+	}
+	return output
+}
+
+// pipelineStep runs cmd over a single file exactly as executeEach would for
+// a whole directory, and returns what it wrote to outDir
+func pipelineStep(srcPath, outDir, targetDir, cmd string, args []string) string {
+	file, err := os.Stat(srcPath)
+	crashOnError(err)
+	mkdirIfNotExist(outDir)
+	if targetDir != "" {
+		mkdirIfNotExist(targetDir)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	executeEach([]os.FileInfo{file},
+		filepath.Dir(srcPath), filepath.Ext(srcPath),
+		outDir, txtExt,
+		targetDir,
+		&wg, cmd, args)
+	wg.Wait()
+
+	return readFile(buildPath(outDir, replaceExtension(file.Name(), txtExt)))
+}
+
+var javaExceptionPattern = regexp.MustCompile(
+	`(?m)^(?:Exception in thread "[^"]*" )?((?:[a-zA-Z_$][\w$]*\.)+[A-Z][\w$]*(?:Exception|Error))(?::.*)?$`)
+
+// findJavaException returns the class of the first exception reported in
+// output, or the empty string if there isn't one
+func findJavaException(output string) string {
+	match := javaExceptionPattern.FindStringSubmatch(output)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+var stackFramePattern = regexp.MustCompile(`(?m)^\s+at [\w$.<>]+\(.*\)$`)
+
+// hasStackTrace reports whether a java exception was dumped into output
+func hasStackTrace(output string) bool {
+	return findJavaException(output) != "" || stackFramePattern.MatchString(output)
+}
+
+func timedOut(output string) bool {
+	return strings.Contains(output, timeoutMessage)
+}
+
+var limitPattern = regexp.MustCompile(`(?m)^` + limitMessage + `(.+)$`)
+
+// exceededLimit names the resource limit the tool which printed output ran
+// into, it is empty if it stayed within all of them
+func exceededLimit(output string) string {
+	match := limitPattern.FindStringSubmatch(output)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
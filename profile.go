@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// span kinds, what a span spent its time doing
+const (
+	jvmSpan      = "jvm"
+	emulatorSpan = "emulator"
+	compareSpan  = "compare"
+)
+
+// how many of the slowest tests to list for each stage
+const slowestShown = 5
+
+// span is a single timed piece of work on a single test
+type span struct {
+	name  string
+	kind  string
+	stage string
+	lane  int
+	start time.Time
+	end   time.Time
+}
+
+// stage is one step of a test run, such as building the compiler set
+type stage struct {
+	name  string
+	start time.Time
+	end   time.Time
+}
+
+// profile records how long everything in a test run takes, it only records
+// anything once enabled, so costs nothing otherwise
+var profile struct {
+	sync.Mutex
+
+	// enabled is only set before any work starts, so it's read without
+	// taking the lock
+	enabled bool
+	stages  []stage
+	spans   []span
+
+	// lanes in use, each goroutine doing work holds one, so that the trace
+	// shows how much of the work actually ran in parallel
+	lanes []bool
+}
+
+// beginStage announces the next step of a test run,
+// ending the last one in the profile
+func beginStage(testSet, step string) {
+	color.Yellow(step + "...")
+	runProgress.start(testSet + " " + step)
+	if !profile.enabled {
+		return
+	}
+
+	profile.Lock()
+	defer profile.Unlock()
+	now := time.Now()
+	endStage(now)
+	profile.stages = append(profile.stages, stage{testSet + " " + step, now, time.Time{}})
+}
+
+func endStage(now time.Time) {
+	if last := len(profile.stages) - 1; last >= 0 && profile.stages[last].end.IsZero() {
+		profile.stages[last].end = now
+	}
+}
+
+// acquireLane finds a lane for a goroutine about to do some work
+func acquireLane() int {
+	if !profile.enabled {
+		return 0
+	}
+	profile.Lock()
+	defer profile.Unlock()
+	for lane, used := range profile.lanes {
+		if !used {
+			profile.lanes[lane] = true
+			return lane
+		}
+	}
+	profile.lanes = append(profile.lanes, true)
+	return len(profile.lanes) - 1
+}
+
+func releaseLane(lane int) {
+	if !profile.enabled {
+		return
+	}
+	profile.Lock()
+	defer profile.Unlock()
+	profile.lanes[lane] = false
+}
+
+// recordSpan notes that the work named name, of the given kind,
+// ran on lane from start until now
+func recordSpan(name, kind string, lane int, start time.Time) {
+	if !profile.enabled {
+		return
+	}
+	end := time.Now()
+	profile.Lock()
+	defer profile.Unlock()
+	stageName := ""
+	if len(profile.stages) > 0 {
+		stageName = profile.stages[len(profile.stages)-1].name
+	}
+	profile.spans = append(profile.spans, span{name, kind, stageName, lane, start, end})
+}
+
+// spanKind is what running cmd spends its time on
+func spanKind(cmd string) string {
+	if cmd == wine {
+		return emulatorSpan
+	}
+	return jvmSpan
+}
+
+// printProfile reports how long each stage and each kind of work took,
+// and which tests were slowest in each stage
+func printProfile() {
+	profile.Lock()
+	defer profile.Unlock()
+	endStage(time.Now())
+
+	color.Cyan("PROFILE...")
+	kinds := map[string]time.Duration{}
+	for _, s := range profile.spans {
+		kinds[s.kind] += s.end.Sub(s.start)
+	}
+	for _, kind := range []string{jvmSpan, emulatorSpan, compareSpan} {
+		fmt.Printf("%-10s %10.3fs summed over every thread\n", kind, kinds[kind].Seconds())
+	}
+
+	for _, st := range profile.stages {
+		spans := []span{}
+		busy := time.Duration(0)
+		for _, s := range profile.spans {
+			if s.stage == st.name {
+				spans = append(spans, s)
+				busy += s.end.Sub(s.start)
+			}
+		}
+		wall := st.end.Sub(st.start)
+		color.Yellow(fmt.Sprintf("%s: %.3fs", st.name, wall.Seconds()))
+		if wall > 0 {
+			fmt.Printf("\t%.3fs of work, %.1f running at once on average\n",
+				busy.Seconds(), busy.Seconds()/wall.Seconds())
+		}
+
+		sort.Slice(spans, func(i, j int) bool {
+			return spans[i].end.Sub(spans[i].start) > spans[j].end.Sub(spans[j].start)
+		})
+		for i, s := range spans {
+			if i == slowestShown {
+				break
+			}
+			fmt.Printf("\t%8.3fs  %-8s %s\n", s.end.Sub(s.start).Seconds(), s.kind, s.name)
+		}
+	}
+}
+
+// traceEvent is an event in the Chrome trace event format, as read by
+// chrome://tracing and Perfetto
+type traceEvent struct {
+	Name     string `json:"name"`
+	Category string `json:"cat,omitempty"`
+	Phase    string `json:"ph"`
+	Time     int64  `json:"ts"`
+	Duration int64  `json:"dur"`
+	Process  int    `json:"pid"`
+	Thread   int    `json:"tid"`
+}
+
+// writeTrace writes every stage and span in the profile to path, stages are
+// drawn on a thread of their own, above the lanes their work ran on
+func writeTrace(path string) {
+	profile.Lock()
+	defer profile.Unlock()
+	if len(profile.stages) == 0 {
+		return
+	}
+	origin := profile.stages[0].start
+	micros := func(t time.Time) int64 {
+		return t.Sub(origin).Nanoseconds() / 1000
+	}
+
+	events := []traceEvent{}
+	for _, st := range profile.stages {
+		events = append(events, traceEvent{
+			Name: st.name, Category: "stage", Phase: "X",
+			Time: micros(st.start), Duration: micros(st.end) - micros(st.start),
+			Process: 1, Thread: 0,
+		})
+	}
+	for _, s := range profile.spans {
+		events = append(events, traceEvent{
+			Name: s.name, Category: s.kind, Phase: "X",
+			Time: micros(s.start), Duration: micros(s.end) - micros(s.start),
+			Process: 1, Thread: s.lane + 1,
+		})
+	}
+
+	contents, err := json.Marshal(map[string]interface{}{"traceEvents": events})
+	crashOnError(err)
+	crashOnError(writeFileAtomic(path, contents, 0666))
+	fmt.Println("wrote a trace of the run to", path)
+}
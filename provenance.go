@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"time"
+)
+
+// provenance records where an expectation came from
+type provenance struct {
+	User        string            `json:"user"`
+	Time        time.Time         `json:"time"`
+	Tools       map[string]string `json:"tools"`
+	Reason      string            `json:"reason,omitempty"`
+	Transaction string            `json:"transaction"`
+}
+
+func (record provenance) String() string {
+	tools := make([]string, 0, len(record.Tools))
+	for tool, hash := range record.Tools {
+		tools = append(tools, tool+"@"+shortHash(hash))
+	}
+	sort.Strings(tools)
+
+	line := "accepted by " + record.User + " on " +
+		record.Time.Format("2006-01-02 15:04:05") + " with " + strings.Join(tools, " ")
+	if record.Reason != "" {
+		line += "\n\treason: " + record.Reason
+	}
+	return line
+}
+
+// readProvenance loads the manifest of every expectation's provenance,
+// keyed by the path of the expectation
+func readProvenance() map[string]provenance {
+	records := map[string]provenance{}
+	contents := readFile(provenanceFile)
+	if contents != "" {
+		json.Unmarshal([]byte(contents), &records)
+	}
+	return records
+}
+
+// recordProvenance works out the provenance of every expectation tx is about
+// to accept, keeping whatever each one replaces in tx, so undoing it can put
+// them back without disturbing the records of any other accept
+func recordProvenance(tx *transaction, reason string) {
+	records := readProvenance()
+	tools := loadRunRecord().Tools
+	username := currentUser()
+
+	tx.Provenance = map[string]*provenance{}
+	tx.Accepted = map[string]provenance{}
+	for _, change := range tx.Changes {
+		output, ok := outputOf(change.Path)
+		if !ok {
+			continue
+		}
+		if previous, ok := records[change.Path]; ok {
+			tx.Provenance[change.Path] = &previous
+		} else {
+			tx.Provenance[change.Path] = nil
+		}
+		if change.Source == "" {
+			continue
+		}
+
+		record := provenance{
+			User:        username,
+			Time:        tx.Time,
+			Tools:       map[string]string{},
+			Reason:      reason,
+			Transaction: tx.ID,
+		}
+		for _, tool := range toolsFor(output.testSet, output.phase) {
+			hash, ok := tools[tool]
+			if !ok {
+				hash = hashFile(buildPath(binDir, tool))
+			}
+			record.Tools[tool] = hash
+		}
+		tx.Accepted[change.Path] = record
+	}
+}
+
+// applyProvenance updates the manifest once tx has been committed, or puts
+// back what it replaced once tx has been undone
+func applyProvenance(tx *transaction) {
+	if tx.Provenance == nil {
+		return
+	}
+	records := readProvenance()
+	for path, previous := range tx.Provenance {
+		if tx.State == committed {
+			if record, ok := tx.Accepted[path]; ok {
+				records[path] = record
+			} else {
+				delete(records, path)
+			}
+		} else if previous != nil {
+			records[path] = *previous
+		} else {
+			delete(records, path)
+		}
+	}
+	writeProvenance(records)
+}
+
+// keepProvenance holds on to the provenance of every expectation tx moves or
+// deletes, so that it follows the expectation to its new path, and comes back
+// with it if tx is undone
+func keepProvenance(tx *transaction) {
+	records := readProvenance()
+	tx.Provenance = map[string]*provenance{}
+	tx.Accepted = map[string]provenance{}
+	for _, change := range tx.Changes {
+		if _, ok := outputOf(change.Path); !ok {
+			continue
+		}
+		if previous, ok := records[change.Path]; ok {
+			tx.Provenance[change.Path] = &previous
+		} else {
+			tx.Provenance[change.Path] = nil
+		}
+	}
+	for path, newPath := range tx.Renames {
+		if record, ok := records[path]; ok {
+			tx.Accepted[newPath] = record
+		}
+	}
+}
+
+func writeProvenance(records map[string]provenance) {
+	contents, err := json.MarshalIndent(records, "", "\t")
+	crashOnError(err)
+	mkdirIfNotExist(expectDir)
+	crashOnError(writeFileAtomic(provenanceFile, contents, 0666))
+}
+
+// outputOf finds which test, set and phase an expectation belongs to
+func outputOf(expectPath string) (testOutput, bool) {
+	for _, testSet := range testSets {
+		for _, phase := range phases {
+			dir := expect.dir(phase, testSet)
+			if dir == "" || !strings.HasPrefix(expectPath, dir+"/") {
+				continue
+			}
+			filename := strings.TrimPrefix(expectPath, dir+"/")
+			return testOutput{replaceExtension(filename, ""), testSet, phase}, true
+		}
+	}
+	return testOutput{}, false
+}
+
+// toolsFor lists the files in bin which play a part in producing the output
+// of phase for testSet
+func toolsFor(testSet, phase string) []string {
+	var tools []string
+	switch testSet {
+	case codegenerator:
+		tools = []string{codegeneratorName}
+	case optimizer:
+		tools = []string{codegeneratorName, optimizerName}
+	case compiler:
+		tools = []string{compilerName}
+	case optimizerStandalone:
+		tools = []string{optimizerName}
+	}
+	switch phase {
+	case run:
+		tools = append(tools, emulatorName)
+	case buildo, asmo:
+		if testSet == compiler {
+			tools = append(tools, optimizerName)
+		}
+	}
+	return tools
+}
+
+// hashTools takes the SHA-256 of every tool in bin the manifest can pin
+func hashTools() map[string]string {
+	hashes := map[string]string{}
+	for _, tool := range pinnedTools {
+		hashes[tool] = hashFile(buildPath(binDir, tool))
+	}
+	return hashes
+}
+
+// hashFile is the hex SHA-256 of the file at path, or "missing" if it
+// can't be read
+func hashFile(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return "missing"
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "missing"
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func currentUser() string {
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		return current.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// printProvenance shows where the expectation at expectPath came from
+func printProvenance(records map[string]provenance, expectPath string) {
+	record, ok := records[expectPath]
+	if !ok {
+		fmt.Println("no provenance recorded")
+		return
+	}
+	fmt.Println(record)
+}
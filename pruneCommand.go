@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+)
+
+// pruneCommand deletes every expectation and result whose test no longer
+// exists, the expectations can be brought back with gtr undo
+func pruneCommand(flags pruneFlags) {
+	tx := beginTransaction("prune")
+	for _, output := range storedOutputs(expect) {
+		if !exists(output.sourcePath()) {
+			tx.remove(output.expectPath())
+		}
+	}
+	results := []string{}
+	for _, output := range storedOutputs(result) {
+		if !exists(output.sourcePath()) {
+			results = append(results, output.resultPath())
+		}
+	}
+
+	if len(tx.Changes)+len(results) == 0 {
+		fmt.Println("nothing to prune")
+		return
+	}
+	for _, change := range tx.Changes {
+		fmt.Println(change.Path)
+	}
+	for _, path := range results {
+		fmt.Println(path)
+	}
+	if flags.dryRun {
+		color.Yellow("dry run, nothing was deleted")
+		return
+	}
+
+	commitTreeChange(tx, "prune")
+	for _, path := range results {
+		crashOnError(os.Remove(path))
+	}
+	color.Green(fmt.Sprint("pruned ", len(tx.Changes), " expectations and ", len(results), " results"))
+	if len(tx.Changes) > 0 {
+		fmt.Println("undo with: gtr undo", tx.ID)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/fatih/color"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// delta debugging
+
+// ddmin is Zeller's minimizing delta debugging algorithm: it returns a subset
+// of units, still in order, which is interesting, and from which no chunk at
+// the finest granularity tried can be removed
+func ddmin(units []string, interesting func([]string) bool) []string {
+	n := 2
+	for len(units) >= 2 {
+		chunks := splitChunks(units, n)
+		reduced := false
+		for i := range chunks {
+			if interesting(chunks[i]) {
+				units = chunks[i]
+				n = 2
+				reduced = true
+				break
+			}
+		}
+		if !reduced {
+			for i := range chunks {
+				complement := joinChunksExcept(chunks, i)
+				if interesting(complement) {
+					units = complement
+					if n > 2 {
+						n--
+					}
+					reduced = true
+					break
+				}
+			}
+		}
+		if !reduced {
+			if n >= len(units) {
+				break
+			}
+			n *= 2
+			if n > len(units) {
+				n = len(units)
+			}
+		}
+	}
+	return units
+}
+
+func splitChunks(units []string, n int) [][]string {
+	chunks := make([][]string, 0, n)
+	for i := 0; i < n; i++ {
+		start, end := measureSlice(len(units), n, i)
+		chunks = append(chunks, units[start:end])
+	}
+	return chunks
+}
+
+func joinChunksExcept(chunks [][]string, skip int) []string {
+	joined := []string{}
+	for i, chunk := range chunks {
+		if i != skip {
+			joined = append(joined, chunk...)
+		}
+	}
+	return joined
+}
+
+// reduceSource shrinks source to a minimal interesting program, first
+// removing whole blocks from the outside in, then single lines, then single
+// tokens, repeating until none of them make any progress
+func reduceSource(source string, interesting func(string) bool) string {
+	cache := map[string]bool{}
+	check := func(units []string) bool {
+		candidate := strings.Join(units, "")
+		known, ok := cache[candidate]
+		if !ok {
+			known = interesting(candidate)
+			cache[candidate] = known
+		}
+		return known
+	}
+
+	for {
+		before := source
+		for level := 1; level <= maxBlockDepth(source); level++ {
+			source = strings.Join(ddmin(splitBlocks(source, level), check), "")
+		}
+		color.Yellow("blocks... %d bytes", len(source))
+
+		source = strings.Join(ddmin(splitLines(source), check), "")
+		color.Yellow("lines... %d bytes", len(source))
+
+		source = strings.Join(ddmin(splitTokens(source), check), "")
+		color.Yellow("tokens... %d bytes", len(source))
+
+		if source == before {
+			return source
+		}
+	}
+}
+
+// reduceAsm shrinks an ASMEmu program one instruction at a time, never
+// trying a program which jumps to, calls, or loads a label it no longer defines
+func reduceAsm(source string, interesting func(string) bool) string {
+	labels := definedLabels(parseAsm(source))
+	cache := map[string]bool{}
+	check := func(units []string) bool {
+		candidate := strings.Join(units, "")
+		known, ok := cache[candidate]
+		if !ok {
+			known = labelsResolve(parseAsm(candidate), labels) && interesting(candidate)
+			cache[candidate] = known
+		}
+		return known
+	}
+
+	for {
+		before := source
+		source = strings.Join(ddmin(splitLines(source), check), "")
+		color.Yellow("instructions... %d left", len(parseAsm(source)))
+
+		if source == before {
+			return source
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// granularities
+// every split keeps the separators attached, so joining the units back
+// together with no separator gives back the original source
+
+func splitLines(source string) []string {
+	lines := strings.SplitAfter(source, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// splitBlocks splits source into lines, except that a line at nesting depth
+// level which opens a block is kept together with everything up to the line
+// which closes it again
+func splitBlocks(source string, level int) []string {
+	units := []string{}
+	depth := 0
+	open := false
+	var block strings.Builder
+	for _, line := range splitLines(source) {
+		start := depth
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if open {
+			block.WriteString(line)
+			if depth <= level {
+				units = append(units, block.String())
+				block.Reset()
+				open = false
+			}
+			continue
+		}
+		if start == level && depth > level {
+			block.WriteString(line)
+			open = true
+			continue
+		}
+		units = append(units, line)
+	}
+	if open {
+		units = append(units, block.String())
+	}
+	return units
+}
+
+func maxBlockDepth(source string) int {
+	depth, max := 0, 0
+	for _, r := range source {
+		switch r {
+		case '{':
+			depth++
+			if depth > max {
+				max = depth
+			}
+		case '}':
+			depth--
+		}
+	}
+	return max
+}
+
+var tokenPattern = regexp.MustCompile(`(?s)("(?:[^"\\]|\\.)*"|\d+\.\d+|\w+|\S)\s*`)
+
+func splitTokens(source string) []string {
+	body := strings.TrimLeftFunc(source, unicode.IsSpace)
+	units := []string{}
+	if leading := source[:len(source)-len(body)]; leading != "" {
+		units = append(units, leading)
+	}
+	return append(units, tokenPattern.FindAllString(body, -1)...)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+func reduceCommand(flags reduceFlags, testname string) {
+	path := testPath(testname, flags.asm)
+	if !exists(path) {
+		color.Magenta(path + " does not exist")
+		os.Exit(1)
+		return
+	}
+	executeTimeout = flags.timeout
+	executeLimits = flags.limits
+
+	color.Cyan("REDUCING...")
+	signature := failureSignature(flags.testSet, flags.phase, path)
+	if signature == "" {
+		color.Magenta(testname + " does not fail in the " + flags.phase +
+			" phase of " + flags.testSet)
+		os.Exit(1)
+		return
+	}
+	color.Yellow("signature: " + signature)
+
+	source := readFile(path)
+	start := time.Now()
+	tries := 0
+	interesting := func(candidate string) bool {
+		tries++
+		return candidateSignature(flags.testSet, flags.phase,
+			filepath.Base(path), candidate) == signature
+	}
+	var reduced string
+	if flags.asm {
+		reduced = reduceAsm(source, interesting)
+	} else {
+		reduced = reduceSource(source, interesting)
+	}
+	color.Yellow("reduced %d bytes to %d bytes in %d tries, %.1f seconds",
+		len(source), len(reduced), tries, time.Since(start).Seconds())
+
+	name := flags.name
+	if name == "" {
+		name = testname + "-reduced"
+	}
+	reducedPath := testPath(name, flags.asm)
+	if !createTest(reducedPath, []byte(reduced)) {
+		os.Exit(1)
+	}
+	color.Green("wrote " + reducedPath)
+
+	if flags.open {
+		openDefaultEditor(reducedPath)
+	}
+}
+
+// failureSignature describes how the test at srcPath fails the given phase
+// of testSet, it is empty when the test doesn't fail at all
+//
+// a failure is either a java exception, a timeout, running into a resource
+// limit, or for the run phase of anything but the codegenerator, output
+// which differs from an unoptimized run: the codegenerator's asm for pika
+// tests, and the source itself for standalone asm
+func failureSignature(testSet, phase, srcPath string) string {
+	workDir, err := ioutil.TempDir("", tempPrefix)
+	crashOnError(err)
+	defer os.RemoveAll(workDir)
+
+	output := runPipeline(binToolchain, testSet, srcPath, workDir)
+	if phase == run {
+		if exception := findJavaException(output.build); exception != "" {
+			return "exception " + exception
+		}
+	}
+	phaseOutput := output.phase(phase)
+	if exception := findJavaException(phaseOutput); exception != "" {
+		return "exception " + exception
+	}
+	if timedOut(phaseOutput) {
+		return "timeout"
+	}
+	if limit := exceededLimit(phaseOutput); limit != "" {
+		return "limit " + limit
+	}
+	if phase != run || testSet == codegenerator {
+		return ""
+	}
+
+	referenceDir := buildPath(workDir, "reference")
+	var reference string
+	if testSet == optimizerStandalone {
+		reference = pipelineStep(srcPath, referenceDir, "", wine, emulatorArgs)
+	} else {
+		reference = runPipeline(binToolchain, codegenerator, srcPath, referenceDir).run
+	}
+	if output.run != reference {
+		return "mismatch"
+	}
+	return ""
+}
+
+// candidateSignature is the failureSignature of a candidate test's source,
+// which only ever lives in a scratch directory
+func candidateSignature(testSet, phase, filename, source string) string {
+	dir, err := ioutil.TempDir("", tempPrefix)
+	crashOnError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, filename)
+	err = ioutil.WriteFile(path, []byte(source), 0777)
+	crashOnError(err)
+	return failureSignature(testSet, phase, path)
+}
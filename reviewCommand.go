@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+func reviewCommand(flags reviewFlags) {
+	sets := testSets
+	if flags.testSet != "" {
+		sets = []string{flags.testSet}
+	}
+
+	known := readKnownFailures()
+	outputs := []testOutput{}
+	for _, output := range failingOutputs(sets) {
+		if flags.all || !known[output] {
+			outputs = append(outputs, output)
+		}
+	}
+	if len(outputs) == 0 {
+		color.Green("nothing to review")
+		return
+	}
+
+	record := loadRunRecord()
+	restore := rawTerminal()
+	defer func() { restore() }()
+
+	accepted := []testOutput{}
+	marked := []testOutput{}
+	skipped := 0
+review:
+	for i := 0; i < len(outputs); i++ {
+		output := outputs[i]
+		fmt.Println()
+		color.Cyan(fmt.Sprintf("[%d/%d] ", i+1, len(outputs)) + output.String())
+		viewOutput(output.phase, output.testSet, output.testname, true, unifiedDiff)
+
+		for {
+			color.Yellow("[a]ccept, [s]kip, [e]dit, [k]nown failure, [q]uit")
+			switch readKey() {
+			case 'a':
+				if reason := acceptRefusal(output, record); reason != "" {
+					color.Red("refused: " + reason)
+					color.Yellow("accept it anyway with gtr accept -force")
+					continue
+				}
+				tx := beginTransaction("review " + output.String())
+				acceptOutput(tx, output)
+				recordProvenance(tx, "")
+				if err := tx.commit(); err != nil {
+					color.Magenta("accept failed: " + err.Error())
+					continue
+				}
+				applyProvenance(tx)
+				accepted = append(accepted, output)
+				continue review
+			case 's', ' ', '\n':
+				skipped++
+				continue review
+			case 'e':
+				restore()
+				openEditor(testPath(output.testname, output.testSet == optimizerStandalone))
+				restore = rawTerminal()
+			case 'k':
+				known[output] = true
+				writeKnownFailures(known)
+				marked = append(marked, output)
+				continue review
+			case 'q', 0:
+				skipped += len(outputs) - i
+				break review
+			}
+		}
+	}
+
+	fmt.Println()
+	green := color.New(color.FgGreen)
+	green.Println("accepted: [", len(accepted), "/", len(outputs), "]")
+	for _, output := range accepted {
+		fmt.Println(output)
+	}
+	if len(marked) > 0 {
+		color.Yellow("marked as known failures:")
+		for _, output := range marked {
+			fmt.Println(output)
+		}
+	}
+	if skipped > 0 {
+		fmt.Println("skipped:", skipped)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// known failures
+// kept one per line as: <test set> <phase> <test name>
+
+func readKnownFailures() map[testOutput]bool {
+	known := map[testOutput]bool{}
+	for _, line := range splitLines(readFile(knownFailuresFile)) {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		known[testOutput{testSet: fields[0], phase: fields[1], testname: fields[2]}] = true
+	}
+	return known
+}
+
+func writeKnownFailures(known map[testOutput]bool) {
+	mkdirIfNotExist(gtrDir)
+	err := ioutil.WriteFile(knownFailuresFile, knownFailuresContents(known), 0666)
+	if err != nil {
+		color.Magenta(err.Error())
+	}
+}
+
+func knownFailuresContents(known map[testOutput]bool) []byte {
+	lines := make([]string, 0, len(known))
+	for output := range known {
+		lines = append(lines, output.testSet+" "+output.phase+" "+output.testname+"\n")
+	}
+	sort.Strings(lines)
+	return []byte(strings.Join(lines, ""))
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+)
+
+// rmCommand deletes a test along with every expectation and result it has,
+// all of which can be brought back with gtr undo
+func rmCommand(flags rmFlags, testname string) {
+	path := testPath(testname, flags.asm)
+	if !exists(path) {
+		color.Magenta(path + " does not exist")
+		os.Exit(1)
+		return
+	}
+
+	tx := beginTransaction("rm " + testname)
+	tx.remove(path)
+	removed := 0
+	for _, output := range testOutputs(testname, flags.asm) {
+		if exists(output.expectPath()) {
+			tx.remove(output.expectPath())
+			removed++
+		}
+		if exists(output.resultPath()) {
+			tx.remove(output.resultPath())
+		}
+	}
+	renameKnownFailures(tx, testname, "", flags.asm)
+	commitTreeChange(tx, "rm")
+
+	color.Green("removed " + path)
+	fmt.Println("removed", removed, "expectations, undo with: gtr undo", tx.ID)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// runRecord is what gtr remembers about the last time tests were run,
+// beyond the results themselves
+type runRecord struct {
+	Time time.Time `json:"time"`
+
+	// Exits holds the exit code of the tool which wrote each result,
+	// results written by tools which exited cleanly are left out
+	Exits map[string]int `json:"exits"`
+
+	// Tools holds the SHA-256 of every tool in bin when the run started
+	Tools map[string]string `json:"tools"`
+}
+
+var (
+	lastRun      = runRecord{Exits: map[string]int{}}
+	lastRunMutex sync.Mutex
+)
+
+// recordExit notes the exit code of the tool which wrote the result at path
+func recordExit(path string, exitCode int) {
+	lastRunMutex.Lock()
+	defer lastRunMutex.Unlock()
+	if exitCode == 0 {
+		delete(lastRun.Exits, path)
+	} else {
+		lastRun.Exits[path] = exitCode
+	}
+}
+
+// exitCode is the exit code of the tool which wrote the result at path
+func (record runRecord) exitCode(path string) int {
+	return record.Exits[path]
+}
+
+// loadRunRecord reads back the record of the last run, so that a partial
+// run only updates the results it rewrote
+func loadRunRecord() runRecord {
+	record := runRecord{Exits: map[string]int{}}
+	contents := readFile(runRecordFile)
+	if contents != "" {
+		json.Unmarshal([]byte(contents), &record)
+	}
+	if record.Exits == nil {
+		record.Exits = map[string]int{}
+	}
+	return record
+}
+
+func saveRunRecord() {
+	lastRunMutex.Lock()
+	defer lastRunMutex.Unlock()
+	lastRun.Time = time.Now()
+	contents, err := json.MarshalIndent(lastRun, "", "\t")
+	crashOnError(err)
+	mkdirIfNotExist(gtrDir)
+	crashOnError(writeFileAtomic(runRecordFile, contents, 0666))
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sandbox is a temporary working directory for a single tool run, holding
+// a copy of its input in in/, and collecting whatever it writes to out/
+type sandbox struct {
+	dir string
+}
+
+func (box sandbox) in() string {
+	return buildPath(box.dir, "in")
+}
+
+func (box sandbox) out() string {
+	return buildPath(box.dir, "out")
+}
+
+// sandboxRun is what running a tool in a sandbox came to
+type sandboxRun struct {
+	output   []byte
+	exitCode int
+	strays   []string
+
+	// interrupted runs were cut short, so nothing they wrote is kept
+	interrupted bool
+}
+
+// executeSandboxed runs cmd over srcPath in a sandbox of its own, so that
+// any scratch files it writes can't clobber those of the tools running
+// alongside it, what it writes to its target directory is moved to
+// targetDir, and anything else it leaves in the sandbox is listed as strays
+//
+// keep, if given, is called with the run before anything is moved to
+// targetDir, so that what the tool printed is never older than what it wrote
+func executeSandboxed(cmd string, args []string, srcPath, targetDir string, keep func(sandboxRun)) sandboxRun {
+	dir, err := ioutil.TempDir("", tempPrefix+"sandbox-")
+	crashOnError(err)
+	box := sandbox{dir}
+	defer os.RemoveAll(box.dir)
+	mkdirIfNotExist(box.in())
+	mkdirIfNotExist(box.out())
+
+	input := buildPath(box.in(), filepath.Base(srcPath))
+	crashOnError(copyFile(srcPath, input))
+
+	// the emulator has always been given "/" as its target, which it ignores
+	completeArgs := append(absolutePaths(args), input)
+	if targetDir != "" {
+		completeArgs = append(completeArgs, box.out()+"/")
+	} else {
+		completeArgs = append(completeArgs, "/")
+	}
+	output, exitCode := execute(box.dir, cmd, completeArgs)
+	if wasInterrupted() {
+		return sandboxRun{exitCode: -1, interrupted: true}
+	}
+
+	// the tool only ever saw the sandbox, so its output names the sandbox
+	// where it would have named the real paths
+	text := string(output)
+	text = replacePath(text, input, srcPath)
+	if targetDir != "" {
+		text = replacePath(text, box.out(), targetDir)
+	}
+	text = replacePath(text, box.dir, ".")
+	run := sandboxRun{output: []byte(text), exitCode: exitCode, strays: box.strays()}
+	if keep != nil {
+		keep(run)
+	}
+
+	if targetDir != "" {
+		for _, file := range getAllFiles(box.out()) {
+			err := moveFile(buildPath(box.out(), file.Name()), buildPath(targetDir, file.Name()))
+			crashOnError(err)
+		}
+	}
+	return run
+}
+
+// replacePath replaces path in text with newPath, in any of the forms a tool
+// may print it in, wine hands windows programs unix paths, which they print
+// back as windows paths, on drive Z: when absolute
+func replacePath(text, path, newPath string) string {
+	windowsPath := strings.Replace(path, "/", `\`, -1)
+	if filepath.IsAbs(path) {
+		text = strings.Replace(text, "Z:"+windowsPath, newPath, -1)
+		text = strings.Replace(text, "z:"+windowsPath, newPath, -1)
+	}
+	text = strings.Replace(text, windowsPath, newPath, -1)
+	return strings.Replace(text, path, newPath, -1)
+}
+
+// strays lists every file the tool wrote to its sandbox other than its
+// output, these would have landed in the shared working directory before
+// tools were sandboxed
+func (box sandbox) strays() []string {
+	strays := []string{}
+	filepath.Walk(box.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path == box.in() || path == box.out() {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() {
+			relative, _ := filepath.Rel(box.dir, path)
+			strays = append(strays, relative)
+		}
+		return nil
+	})
+	return strays
+}
+
+// absolutePaths makes every argument which names an existing file absolute,
+// so that it still names the same file from inside a sandbox
+func absolutePaths(args []string) []string {
+	absolute := make([]string, len(args))
+	for i, arg := range args {
+		absolute[i] = arg
+		if strings.HasPrefix(arg, "-") || !exists(arg) {
+			continue
+		}
+		if path, err := filepath.Abs(arg); err == nil {
+			absolute[i] = path
+		}
+	}
+	return absolute
+}
+
+// moveFile renames src to dst, copying it across when they are on
+// different filesystems, as the sandboxes and the result directory may be,
+// either way dst is never left half written
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	contents, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(dst, contents, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// treeSnapshot is the modification time of every file under a directory
+type treeSnapshot map[string]time.Time
+
+// snapshotProject records every file in the working directory other than the
+// results and gtr's own files, which gtr itself writes while tools run, and
+// the tools, tests and git history, which are too big to walk twice a step
+func snapshotProject() treeSnapshot {
+	snapshot := treeSnapshot{}
+	skip := map[string]bool{
+		filepath.Clean(resultDir): true, filepath.Clean(gtrDir): true,
+		filepath.Clean(binDir): true, filepath.Dir(filepath.Clean(pikaDir)): true, ".git": true,
+	}
+	filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if skip[path] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		snapshot[path] = info.ModTime()
+		return nil
+	})
+	return snapshot
+}
+
+// changedSince lists every file which was created, changed or deleted
+// between before and snapshot
+func (snapshot treeSnapshot) changedSince(before treeSnapshot) []string {
+	changed := []string{}
+	for path, modified := range snapshot {
+		if previous, ok := before[path]; !ok || !previous.Equal(modified) {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := snapshot[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
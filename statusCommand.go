@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// statusCommand shows anything in the test tree which needs attention,
+// in the spirit of git status
+func statusCommand() {
+	untested := []string{}
+	changed := []string{}
+	for _, testname := range testNames(compiler) {
+		status := testStatus(testname, []string{codegenerator, optimizer, compiler})
+		if status == "untested" {
+			untested = append(untested, testPath(testname, false))
+		} else if status == "changed" {
+			changed = append(changed, testPath(testname, false))
+		}
+	}
+	for _, testname := range testNames(optimizerStandalone) {
+		status := testStatus(testname, []string{optimizerStandalone})
+		if status == "untested" {
+			untested = append(untested, testPath(testname, true))
+		} else if status == "changed" {
+			changed = append(changed, testPath(testname, true))
+		}
+	}
+
+	orphaned := []string{}
+	for _, tree := range []testDirTree{expect, result} {
+		for _, output := range storedOutputs(tree) {
+			if !exists(output.sourcePath()) {
+				orphaned = append(orphaned, outputPath(tree, output.phase, output.testSet, output.testname))
+			}
+		}
+	}
+
+	differing := []string{}
+	for _, output := range storedOutputs(result) {
+		if !exists(output.sourcePath()) || !exists(output.expectPath()) {
+			continue
+		}
+		if newer(output.resultPath(), output.expectPath()) &&
+			!compareResult(output.resultPath(), output.expectPath()) {
+			differing = append(differing, output.resultPath())
+		}
+	}
+
+	printStatus("tests without any expectations:", color.FgRed, untested)
+	printStatus("tests changed since they were last run:", color.FgYellow, changed)
+	printStatus("results which differ from their expectations:", color.FgRed, differing)
+	printStatus("expectations and results whose test no longer exists:", color.FgMagenta, orphaned)
+
+	if len(untested)+len(changed)+len(differing)+len(orphaned) == 0 {
+		fmt.Println("nothing to do, every test has expectations which match its results")
+	}
+}
+
+// testStatus is "untested" if a test has no expectations in any of testSets,
+// "changed" if its source is newer than its newest result, and empty otherwise
+func testStatus(testname string, sets []string) string {
+	source := testPath(testname, sets[0] == optimizerStandalone)
+	hasExpect := false
+	var newestResult time.Time
+	for _, testSet := range sets {
+		for _, phase := range phases {
+			output := testOutput{testname, testSet, phase}
+			if output.expectPath() == "" {
+				continue
+			}
+			if exists(output.expectPath()) {
+				hasExpect = true
+			}
+			if ran, ok := modTime(output.resultPath()); ok && ran.After(newestResult) {
+				newestResult = ran
+			}
+		}
+	}
+
+	if !hasExpect {
+		return "untested"
+	}
+	if edited, ok := modTime(source); ok && !newestResult.IsZero() && edited.After(newestResult) {
+		return "changed"
+	}
+	return ""
+}
+
+func printStatus(heading string, attribute color.Attribute, paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	sort.Strings(paths)
+	fmt.Println(heading)
+	colored := color.New(attribute)
+	for _, path := range paths {
+		colored.Println("\t" + path)
+	}
+	fmt.Println()
+}
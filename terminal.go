@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// rawTerminal puts the terminal into cbreak mode, so that single key presses
+// can be read without waiting for enter, and returns a function which puts
+// the terminal back the way it was
+func rawTerminal() func() {
+	state := strings.TrimSpace(stty("-g"))
+	stty("cbreak", "-echo")
+	return func() {
+		stty(state)
+	}
+}
+
+func stty(args ...string) string {
+	task := exec.Command("stty", args...)
+	task.Stdin = os.Stdin
+	output, _ := task.Output()
+	return string(output)
+}
+
+// terminalWidth is the number of columns in the terminal, from $COLUMNS or
+// stty, defaulting to 80 when neither knows, such as when piped
+func terminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	size := strings.Fields(stty("size"))
+	if len(size) == 2 {
+		if columns, err := strconv.Atoi(size[1]); err == nil && columns > 0 {
+			return columns
+		}
+	}
+	return 80
+}
+
+// startPager sends everything printed from here on through $PAGER, or less
+// without it, keeping colors, and returns a function which waits for the
+// pager to be closed, it does nothing unless stdout is a terminal
+func startPager() func() {
+	info, err := os.Stdout.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return func() {}
+	}
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less"
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return func() {}
+	}
+	task := exec.Command("sh", "-c", pager)
+	task.Stdin, task.Stdout, task.Stderr = reader, os.Stdout, os.Stderr
+	task.Env = os.Environ()
+	if os.Getenv("LESS") == "" {
+		// -R passes colors through, -F and -X leave short output on screen
+		task.Env = append(task.Env, "LESS=FRX")
+	}
+	if err := task.Start(); err != nil {
+		reader.Close()
+		writer.Close()
+		return func() {}
+	}
+	reader.Close()
+
+	// color decided whether to color its output when stdout was still the
+	// terminal, so it keeps coloring once stdout is the pager
+	stdout, output := os.Stdout, color.Output
+	os.Stdout, color.Output = writer, writer
+	return func() {
+		writer.Close()
+		task.Wait()
+		os.Stdout, color.Output = stdout, output
+	}
+}
+
+// readKey waits for a single key press
+func readKey() byte {
+	key := make([]byte, 1)
+	_, err := os.Stdin.Read(key)
+	if err != nil {
+		return 0
+	}
+	return key[0]
+}
+
+// openEditor opens path in $EDITOR, in this terminal, waiting for it to close,
+// without $EDITOR it falls back to the default editor for the file
+func openEditor(path string) {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		openDefaultEditor(path)
+		return
+	}
+	task := exec.Command("sh", "-c", editor+` "$0"`, path)
+	task.Stdin, task.Stdout, task.Stderr = os.Stdin, os.Stdout, os.Stderr
+	task.Run()
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"runtime"
 	"time"
 
@@ -20,57 +21,102 @@ func testCommand(flags testFlags) {
 	}
 
 	runtime.GOMAXPROCS(flags.threads)
+	handleInterrupts()
+	executeLimits = flags.limits
+	lastRun = loadRunRecord()
+	lastRun.Tools = hashTools()
+	pins := readPins()
+	unpinned := unpinnedTools(pins, lastRun.Tools)
+	if flags.pinned {
+		if len(pins) == 0 {
+			color.Magenta("nothing is pinned in " + toolchainManifestFile + ", pin the tools in " + binDir + " with gtr toolchain pin")
+			os.Exit(1)
+		}
+		unpinned = append(unpinned, missingPins(pins, lastRun.Tools)...)
+	}
+	if len(unpinned) > 0 {
+		if flags.pinned {
+			color.Magenta("the tools in " + binDir + " don't match the pinned toolchain:")
+		} else {
+			color.Yellow("warning, the tools in " + binDir + " don't match the pinned toolchain:")
+		}
+		for _, line := range unpinned {
+			fmt.Println("\t" + line)
+		}
+		if flags.pinned {
+			os.Exit(1)
+		}
+		color.Yellow("results accepted from this run may disagree with everyone else's")
+	}
+	profile.enabled = flags.profile != ""
 
-	start := time.Now().UnixNano()
+	start := time.Now()
 	if flags.clean {
 		fmt.Print("CLEANING...")
 		cleanResultDirs()
+		lastRun.Exits = map[string]int{}
 		fmt.Println(" done")
 	}
-	if flags.codegen {
+	// once interrupted, every step left is skipped
+	step := func(testSet, name string, batch func(int)) {
+		if !wasInterrupted() {
+			beginStage(testSet, name)
+			batch(flags.threads)
+		}
+	}
+	if flags.codegen && !wasInterrupted() {
 		color.Cyan("GENERATING CODE...")
-		color.Yellow("building...")
-		batchCodeGen(flags.threads)
-		color.Yellow("running...")
-		batchRunUnoptimized(flags.threads)
+		step(codegenerator, "building", batchCodeGen)
+		step(codegenerator, "running", batchRunUnoptimized)
 	}
-	if flags.optimize {
+	if flags.optimize && !wasInterrupted() {
 		color.Cyan("OPTIMIZING...")
-		color.Yellow("building...")
-		batchOptimize(flags.threads)
-		color.Yellow("running...")
-		batchRunOptimized(flags.threads)
+		step(optimizer, "building", batchOptimize)
+		step(optimizer, "running", batchRunOptimized)
 		if flags.reoptimize {
-			color.Yellow("reoptimizing...")
-			batchReoptimizeOptimize(flags.threads)
+			step(optimizer, "reoptimizing", batchReoptimizeOptimize)
 		}
 	}
-	if flags.compile {
+	if flags.compile && !wasInterrupted() {
 		color.Cyan("COMPILING...")
-		color.Yellow("building...")
-		batchCompile(flags.threads)
-		color.Yellow("running...")
-		batchRunCompiled(flags.threads)
+		step(compiler, "building", batchCompile)
+		step(compiler, "running", batchRunCompiled)
 		if flags.reoptimize {
-			color.Yellow("reoptimizing...")
-			batchReoptimizeCompile(flags.threads)
+			step(compiler, "reoptimizing", batchReoptimizeCompile)
 		}
 	}
-	if flags.optimizeStandalone {
+	if flags.optimizeStandalone && !wasInterrupted() {
 		color.Cyan("OPTIMIZING STANDALONE ASM...")
-		color.Yellow("building...")
-		batchOptimizeStandalone(flags.threads)
-		color.Yellow("running...")
-		batchRunOptimizedStandalone(flags.threads)
+		step(optimizerStandalone, "building", batchOptimizeStandalone)
+		step(optimizerStandalone, "running", batchRunOptimizedStandalone)
 		if flags.reoptimize {
-			color.Yellow("reoptimizing...")
-			batchReoptimizeOptimizeStandalone(flags.threads)
+			step(optimizerStandalone, "reoptimizing", batchReoptimizeOptimizeStandalone)
 		}
 	}
-	end := time.Now().UnixNano()
-	delta := end - start
-	seconds := delta / (1000000000)
-	fraction := delta % (1000000000)
-	readable := fmt.Sprintf("%d.%d", seconds, fraction)
-	fmt.Println("completed in", readable, "seconds")
+	if wasInterrupted() {
+		runProgress.printPartialSummary()
+		saveRunRecord()
+		os.Exit(interruptedExitCode)
+	}
+	if profile.enabled {
+		printProfile()
+		writeTrace(flags.profile)
+	}
+	saveRunRecord()
+
+	measured := []string{}
+	if flags.optimize {
+		measured = append(measured, optimizer)
+	}
+	if flags.compile {
+		measured = append(measured, compiler)
+	}
+	if flags.optimizeStandalone {
+		measured = append(measured, optimizerStandalone)
+	}
+	if len(measured) > 0 {
+		updateMetrics(measured)
+	}
+
+	fmt.Printf("completed in %.3f seconds\n", time.Since(start).Seconds())
 }
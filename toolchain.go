@@ -0,0 +1,35 @@
+package main
+
+// toolchain is a directory of the jars and emulator tests are run with,
+// bin unless comparing against, or bisecting through, other builds
+type toolchain struct {
+	dir string
+}
+
+var binToolchain = toolchain{binDir}
+
+func (tools toolchain) jarArgs(name string) []string {
+	return []string{"-ea", "-jar", buildPath(tools.dir, name)}
+}
+
+func (tools toolchain) compilerArgs() []string {
+	return tools.jarArgs(compilerName)
+}
+
+func (tools toolchain) codegeneratorArgs() []string {
+	return tools.jarArgs(codegeneratorName)
+}
+
+func (tools toolchain) optimizerArgs() []string {
+	return tools.jarArgs(optimizerName)
+}
+
+// emulatorArgs runs the toolchain's own emulator, or bin's when it doesn't
+// have one, as most builds only change the jars
+func (tools toolchain) emulatorArgs() []string {
+	emulator := buildPath(tools.dir, emulatorName)
+	if !exists(emulator) {
+		emulator = buildPath(binDir, emulatorName)
+	}
+	return []string{emulator}
+}
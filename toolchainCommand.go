@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+)
+
+// toolchainCommand manages the toolchain manifest, which pins the build of
+// each tool in bin everyone is meant to be testing with
+func toolchainCommand(flags toolchainFlags, subcommand string, tools []string) {
+	switch subcommand {
+	case "pin":
+		pinCommand(flags, tools)
+	case "status":
+		toolchainStatus()
+	}
+}
+
+// pinCommand pins tools at the builds currently in bin, every tool in bin
+// when none are given, leaving the pins of any others as they were
+func pinCommand(flags toolchainFlags, tools []string) {
+	if len(tools) == 0 {
+		for _, tool := range pinnedTools {
+			if exists(buildPath(binDir, tool)) {
+				tools = append(tools, tool)
+			}
+		}
+	}
+	if len(tools) == 0 {
+		color.Magenta("there are no tools in " + binDir + " to pin")
+		os.Exit(1)
+		return
+	}
+	for _, tool := range tools {
+		if !exists(buildPath(binDir, tool)) {
+			color.Magenta(buildPath(binDir, tool) + " does not exist")
+			os.Exit(1)
+			return
+		}
+	}
+
+	pins := readPins()
+	for _, tool := range tools {
+		path := buildPath(binDir, tool)
+		pinned := pin{SHA256: hashFile(path), Version: flags.version}
+		if pinned.Version == "" && tool != emulatorName {
+			if manifest, err := jarManifest(path); err == nil {
+				pinned.Version = manifest["Implementation-Version"]
+			}
+		}
+		pins[tool] = pinned
+		fmt.Println("pinned", tool, "at", pinned)
+	}
+	mkdirIfNotExist(expectDir)
+	writePins(pins)
+	color.Green("wrote " + toolchainManifestFile + ", commit it so everyone tests with the same tools")
+}
+
+// toolchainStatus shows whether each tool in bin matches its pin
+func toolchainStatus() {
+	pins := readPins()
+	if len(pins) == 0 {
+		color.Magenta("nothing is pinned, pin the tools in " + binDir + " with gtr toolchain pin")
+		os.Exit(1)
+		return
+	}
+
+	hashes := hashTools()
+	for _, tool := range pinnedTools {
+		pinned, ok := pins[tool]
+		if !ok {
+			if exists(buildPath(binDir, tool)) {
+				color.New(color.FgYellow).Printf("%-10s", "unpinned")
+				fmt.Println(tool)
+			}
+			continue
+		}
+		if hashes[tool] == pinned.SHA256 {
+			color.New(color.FgGreen).Printf("%-10s", "ok")
+			fmt.Println(tool, pinned)
+		}
+	}
+	unpinned := unpinnedTools(pins, hashes)
+	for _, line := range unpinned {
+		color.New(color.FgRed).Printf("%-10s", "differs")
+		fmt.Println(line)
+	}
+	if len(unpinned) > 0 {
+		os.Exit(1)
+	}
+}
@@ -3,6 +3,9 @@ package main
 type testResult struct {
 	name   string
 	result bool
+
+	// limit is the resource limit the tool which wrote the result ran into
+	limit string
 }
 
 type testStorageDir struct {
@@ -19,3 +22,48 @@ type testDirTree struct {
 	buildo testStorageDir
 	asmo   testStorageDir
 }
+
+// dirs are every directory in the tree, by phase then set
+func (tree testDirTree) dirs() []string {
+	dirs := []string{}
+	for _, phase := range phases {
+		for _, testSet := range testSets {
+			if dir := tree.dir(phase, testSet); dir != "" {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+// dir is where output of phase for testSet is stored in the tree,
+// it is empty when testSet has no such phase
+func (tree testDirTree) dir(phase, testSet string) string {
+	var storage testStorageDir
+	switch phase {
+	case build:
+		storage = tree.build
+	case run:
+		storage = tree.run
+	case asm:
+		storage = tree.asm
+	case buildo:
+		storage = tree.buildo
+	case asmo:
+		storage = tree.asmo
+	default:
+		return ""
+	}
+
+	switch testSet {
+	case codegenerator:
+		return storage.codegenerator
+	case compiler:
+		return storage.compiler
+	case optimizer:
+		return storage.optimizer
+	case optimizerStandalone:
+		return storage.optimizerStandalone
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+)
+
+// undoCommand rolls back the accept with the given id,
+// or the most recent one still in effect when id is empty
+func undoCommand(flags undoFlags, id string) {
+	history := readHistory()
+
+	var tx *transaction
+	for _, candidate := range history {
+		if id == "" && (candidate.State == committed || candidate.State == pending) {
+			tx = candidate
+		} else if candidate.ID == id {
+			tx = candidate
+		}
+	}
+	if tx == nil {
+		if id == "" {
+			color.Magenta("there is nothing to undo")
+		} else {
+			color.Magenta("there is no accept " + id + ", see gtr accept -history")
+		}
+		os.Exit(1)
+		return
+	}
+	if tx.State == undone || tx.State == rolledBack {
+		color.Magenta(tx.ID + " is already " + tx.State)
+		os.Exit(1)
+		return
+	}
+
+	// undoing anything but the latest change to a file would throw away
+	// the changes made to it since
+	conflicts := []*transaction{}
+	for _, later := range history {
+		if later.ID <= tx.ID || later.State != committed {
+			continue
+		}
+		for _, change := range tx.Changes {
+			if later.touches(change.Path) {
+				conflicts = append(conflicts, later)
+				break
+			}
+		}
+	}
+	if len(conflicts) > 0 && !flags.force {
+		color.Magenta(tx.ID + " changed files which were changed again by:")
+		for _, later := range conflicts {
+			fmt.Println(later.ID, later.Command)
+		}
+		color.Magenta("undo those first, or use -force to throw them away")
+		os.Exit(1)
+		return
+	}
+
+	err := tx.undo()
+	if err != nil {
+		color.Magenta("undo failed: " + err.Error())
+		os.Exit(1)
+	}
+	applyProvenance(tx)
+	followRenames(tx)
+	color.Green("undid " + tx.ID + ": " + tx.Command)
+	fmt.Println("restored", len(tx.Changes), "files")
+}
+
+func printHistory() {
+	history := readHistory()
+	if len(history) == 0 {
+		fmt.Println("no accepts have been recorded")
+		return
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		tx := history[i]
+		line := fmt.Sprintf("%s  %-11s %4d files  %s",
+			tx.ID, tx.State, len(tx.Changes), tx.Command)
+		if tx.State == committed {
+			fmt.Println(line)
+		} else {
+			color.Yellow(line)
+		}
+	}
+}
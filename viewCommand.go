@@ -1,17 +1,26 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
-	"strings"
+	"path/filepath"
+	"sort"
 
 	"github.com/fatih/color"
 )
 
-func viewCommand(flags viewFlags, testname string) {
+func viewCommand(flags viewFlags, targets []string) {
+	if flags.optimizations {
+		viewOptimizations(flags, targets[0])
+		return
+	}
+	if flags.failed || len(targets) > 1 || isGlob(targets[0]) {
+		viewMany(flags, targets)
+		return
+	}
+	testname := targets[0]
+
 	if flags.test {
 		color.Cyan("TEST...")
 		path := ""
@@ -33,17 +42,17 @@ func viewCommand(flags viewFlags, testname string) {
 	if flags.asm {
 		phase := asm
 		color.Cyan("ASM...")
-		viewOutput(phase, flags.testSet, testname, flags.diff)
+		viewOutput(phase, flags.testSet, testname, flags.diff, flags.diffStyle)
 	}
 	if flags.build {
 		phase := build
 		color.Cyan("BUILD...")
-		viewOutput(phase, flags.testSet, testname, flags.diff)
+		viewOutput(phase, flags.testSet, testname, flags.diff, flags.diffStyle)
 	}
 	if flags.run {
 		phase := run
 		color.Cyan("RUN...")
-		viewOutput(phase, flags.testSet, testname, flags.diff)
+		viewOutput(phase, flags.testSet, testname, flags.diff, flags.diffStyle)
 	}
 	if flags.testSet == codegenerator && (flags.asmo || flags.buildo) {
 		color.Magenta("there is no reoptimize phase for the codegenerator")
@@ -52,37 +61,28 @@ func viewCommand(flags viewFlags, testname string) {
 	if flags.asmo {
 		phase := asmo
 		color.Cyan("ASMO...")
-		viewOutput(phase, flags.testSet, testname, flags.diff)
+		viewOutput(phase, flags.testSet, testname, flags.diff, flags.diffStyle)
 	}
 	if flags.buildo {
 		phase := buildo
-		color.Cyan("ASMO...")
-		viewOutput(phase, flags.testSet, testname, flags.diff)
+		color.Cyan("BUILDO...")
+		viewOutput(phase, flags.testSet, testname, flags.diff, flags.diffStyle)
 	}
 }
 
-// TODO need to take into account asmo files
-func viewOutput(phase, testSet, testname string, diff bool) {
-	var resultPath, expectPath string
-	if phase == asm || phase == asmo {
-		if testSet == optimizer || testSet == optimizerStandalone ||
-			phase == asmo {
-			resultPath = buildPath(resultDir, phase, testSet, testname+asmoExt)
-			expectPath = buildPath(expectDir, phase, testSet, testname+asmoExt)
-		} else {
-			resultPath = buildPath(resultDir, phase, testSet, testname+asmExt)
-			expectPath = buildPath(expectDir, phase, testSet, testname+asmExt)
-		}
-	} else {
-		resultPath = buildPath(resultDir, phase, testSet, testname+txtExt)
-		expectPath = buildPath(expectDir, phase, testSet, testname+txtExt)
+func viewOutput(phase, testSet, testname string, diff bool, diffStyle string) {
+	resultPath := outputPath(result, phase, testSet, testname)
+	expectPath := outputPath(expect, phase, testSet, testname)
+
+	if exists(expectPath) {
+		color.Yellow("provenance...")
+		printProvenance(readProvenance(), expectPath)
 	}
 
 	if diff {
 		color.Yellow("diff...")
 		if exists(resultPath) && exists(expectPath) {
-			output := makeDiff(expectPath, resultPath)
-			printDiff(output)
+			printDiff(makeDiff(expectPath, resultPath), diffStyle)
 		}
 
 		if !exists(expectPath) {
@@ -122,34 +122,180 @@ func viewOutput(phase, testSet, testname string, diff bool) {
 	}
 }
 
-func makeDiff(expectPath, resultPath string) string {
-	gitDiff := exec.Command("git", "diff", "--no-index", expectPath, resultPath)
-	var stdOut bytes.Buffer
-	gitDiff.Stdout = &stdOut
-	gitDiff.Run()
-	return string(stdOut.Bytes())
-}
+// viewMany shows the diff of every output which matches targets, or which
+// failed in the last run with -failed, one after another in a pager
+func viewMany(flags viewFlags, targets []string) {
+	for _, target := range targets {
+		if _, err := filepath.Match(target, ""); err != nil {
+			color.Magenta(target + " is not a valid glob")
+			os.Exit(1)
+		}
+	}
 
-func printDiff(diff string) {
-	lines := strings.Split(diff, "\n")
-	if len(lines) < 4 {
+	outputs := []testOutput{}
+	if flags.failed {
+		for _, output := range failingOutputs(flags.testSets) {
+			if len(targets) == 0 || matchesAny(output.testname, targets) {
+				outputs = append(outputs, output)
+			}
+		}
+	} else {
+		for _, output := range storedOutputs(result) {
+			if containsString(flags.testSets, output.testSet) && matchesAny(output.testname, targets) {
+				outputs = append(outputs, output)
+			}
+		}
+	}
+
+	selected := flags.phases()
+	if len(selected) > 0 {
+		filtered := []testOutput{}
+		for _, output := range outputs {
+			if containsString(selected, output.phase) {
+				filtered = append(filtered, output)
+			}
+		}
+		outputs = filtered
+	}
+	if len(outputs) == 0 {
+		color.Yellow("nothing to view")
 		return
 	}
-	for _, line := range lines[2:] {
-		if len(line) == 0 {
-			continue
+	sort.Slice(outputs, func(i, j int) bool {
+		a, b := outputs[i], outputs[j]
+		if a.testname != b.testname {
+			return a.testname < b.testname
+		}
+		if a.testSet != b.testSet {
+			return a.testSet < b.testSet
+		}
+		return phaseIndex(a.phase) < phaseIndex(b.phase)
+	})
+
+	closePager := startPager()
+	defer closePager()
+
+	lastTest := ""
+	for _, output := range outputs {
+		if flags.test && output.sourcePath() != lastTest {
+			lastTest = output.sourcePath()
+			color.Cyan("==> " + lastTest)
+			fmt.Print(readFile(lastTest))
+		}
+		color.Cyan("==> " + output.testname + "  " + output.testSet + "  " + output.phase)
+		viewOutput(output.phase, output.testSet, output.testname, true, flags.diffStyle)
+		fmt.Println()
+	}
+}
+
+func phaseIndex(phase string) int {
+	for i, p := range phases {
+		if p == phase {
+			return i
+		}
+	}
+	return len(phases)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
 		}
-		r := line[0]
-		lightBlue := color.New(color.FgHiBlue)
-		switch r {
-		case '+':
-			color.Green(line)
-		case '-':
-			color.Red(line)
-		case '@':
-			lightBlue.Println(line)
-		default:
-			fmt.Println(line)
+	}
+	return false
+}
+
+// viewOptimizations diffs the asm the optimizer was given for a test against
+// the asm it wrote, and compares how many instructions and cycles each takes
+func viewOptimizations(flags viewFlags, testname string) {
+	var before, after string
+	switch flags.testSet {
+	case optimizer:
+		before = outputPath(result, asm, codegenerator, testname)
+		after = outputPath(result, asm, optimizer, testname)
+	case optimizerStandalone:
+		before = testPath(testname, true)
+		after = outputPath(result, asm, optimizerStandalone, testname)
+	case compiler:
+		before = outputPath(result, asm, compiler, testname)
+		after = outputPath(result, asmo, compiler, testname)
+	}
+	for _, path := range []string{before, after} {
+		if !exists(path) {
+			color.Magenta(path + " does not exist, run gtr test first")
+			os.Exit(1)
+			return
 		}
 	}
+
+	color.Cyan("OPTIMIZATIONS...")
+	printDiff(makeDiff(before, after), flags.diffStyle)
+	if compareResult(before, after) {
+		fmt.Println("the optimizer left the program unchanged")
+	}
+
+	color.Cyan("STATISTICS...")
+	printAsmComparison(countAsm(parseAsm(readFile(before))), countAsm(parseAsm(readFile(after))))
+}
+
+// printAsmComparison tabulates the counts of a program before and after
+// optimizing, along with every opcode whose count changed
+func printAsmComparison(before, after asmStats) {
+	fmt.Printf("%-16s %10s %10s %16s\n", "", "before", "after", "change")
+	printStatChange("instructions", before.instructions, after.instructions)
+	printStatChange("est. cycles", before.cycles, after.cycles)
+	fmt.Println("cycles are a static estimate, every instruction counts once")
+
+	opcodes := []string{}
+	for opcode := range before.opcodes {
+		opcodes = append(opcodes, opcode)
+	}
+	for opcode := range after.opcodes {
+		if _, ok := before.opcodes[opcode]; !ok {
+			opcodes = append(opcodes, opcode)
+		}
+	}
+	changes := func(opcode string) int {
+		change := after.opcodes[opcode] - before.opcodes[opcode]
+		if change < 0 {
+			return -change
+		}
+		return change
+	}
+	sort.Slice(opcodes, func(i, j int) bool {
+		if changes(opcodes[i]) != changes(opcodes[j]) {
+			return changes(opcodes[i]) > changes(opcodes[j])
+		}
+		return opcodes[i] < opcodes[j]
+	})
+
+	color.Yellow("opcodes...")
+	unchanged := true
+	for _, opcode := range opcodes {
+		if changes(opcode) == 0 {
+			continue
+		}
+		unchanged = false
+		printStatChange(opcode, before.opcodes[opcode], after.opcodes[opcode])
+	}
+	if unchanged {
+		fmt.Println("every opcode is used as often as before")
+	}
+}
+
+func printStatChange(name string, before, after int) {
+	change := fmt.Sprintf("%+d", after-before)
+	if before != 0 {
+		change += fmt.Sprintf(" (%+.1f%%)", 100*float64(after-before)/float64(before))
+	}
+	line := fmt.Sprintf("%-16s %10d %10d %16s", name, before, after, change)
+	switch {
+	case after < before:
+		color.New(color.FgGreen).Println(line)
+	case after > before:
+		color.New(color.FgRed).Println(line)
+	default:
+		fmt.Println(line)
+	}
 }